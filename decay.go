@@ -0,0 +1,153 @@
+package tdigest
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// Every sample is registered with this weight (scaled by its
+	// decay) so that some precision is kept after rounding.
+	decayResolution = 1 << 10
+
+	// The weights of new samples grow exponentially as time goes by.
+	// Once they grow past this factor, all the counts are scaled back.
+	decayRescaleFactor = 1 << 20
+)
+
+// ExponentiallyDecayingDigest is a digest in which the weight of the
+// samples decays exponentially with their age.
+//
+// With a half-life of 10 minutes, a sample registered 10 minutes ago
+// counts half as much as a fresh one, a sample registered an hour ago
+// counts 1/64th as much and so on. This makes estimations track the
+// recent behaviour of a stream without having to keep time windows.
+//
+// Instead of rescaling every centroid on each addition, new samples
+// are given exponentially larger weights (forward decay), which is
+// equivalent. Since centroid counts are integers, weights are rounded,
+// and the counts of old centroids are scaled back periodically:
+// centroids whose weight becomes negligible are discarded then.
+//
+// Like TDigest, an ExponentiallyDecayingDigest is not safe for
+// concurrent use.
+type ExponentiallyDecayingDigest struct {
+	digest    *TDigest
+	lambda    float64
+	reference time.Time
+	now       func() time.Time
+}
+
+// NewDecaying creates a digest in which the weight of the samples
+// halves every halfLife. The options configure the underlying digest
+// like in New.
+//
+// This will emit an error if halfLife is not positive or if the
+// options are invalid.
+func NewDecaying(halfLife time.Duration, options ...tdigestOption) (*ExponentiallyDecayingDigest, error) {
+	if halfLife <= 0 {
+		return nil, fmt.Errorf("halfLife must be > 0, got %v", halfLife)
+	}
+
+	digest, err := New(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &ExponentiallyDecayingDigest{
+		digest: digest,
+		lambda: math.Ln2 / halfLife.Seconds(),
+		now:    time.Now,
+	}
+	d.reference = d.now()
+	return d, nil
+}
+
+// AddWeighted registers a new sample in the digest, with the weight of
+// count fresh samples. Refer to TDigest.AddWeighted for more details.
+func (d *ExponentiallyDecayingDigest) AddWeighted(value float64, count uint64) error {
+	if count == 0 {
+		return fmt.Errorf("illegal datapoint <value: %.4f, count: %d>", value, count)
+	}
+
+	factor := d.factor(d.now())
+	if factor > decayRescaleFactor {
+		d.rescale()
+		factor = d.factor(d.now())
+	}
+
+	weight := math.Round(float64(count) * decayResolution * factor)
+	return d.digest.AddWeighted(value, uint64(weight))
+}
+
+// Add is an alias for AddWeighted(x,1)
+func (d *ExponentiallyDecayingDigest) Add(value float64) error {
+	return d.AddWeighted(value, 1)
+}
+
+// Merge joins a given digest into itself, taking the decay that both
+// digests went through into account.
+func (d *ExponentiallyDecayingDigest) Merge(other *ExponentiallyDecayingDigest) (err error) {
+	// Expresses the weights of other relative to our reference
+	factor := math.Exp(d.lambda * other.reference.Sub(d.reference).Seconds())
+
+	other.digest.summary.Perm(d.digest.rng, func(mean float64, count uint64) bool {
+		weight := math.Round(float64(count) * factor)
+		if weight < 1 {
+			return true
+		}
+		err = d.digest.AddWeighted(mean, uint64(weight))
+		return err == nil
+	})
+	return err
+}
+
+// Quantile returns the desired percentile estimation, weighting the
+// samples by their decay. Refer to TDigest.Quantile for more details.
+func (d *ExponentiallyDecayingDigest) Quantile(q float64) float64 {
+	return d.digest.Quantile(q)
+}
+
+// CDF computes the (decayed) fraction in which all samples are less
+// than or equal to the given value. Refer to TDigest.CDF for more
+// details.
+func (d *ExponentiallyDecayingDigest) CDF(value float64) float64 {
+	return d.digest.CDF(value)
+}
+
+// Count returns the effective number of samples in the digest, i.e.:
+// the sum of the decayed weights of all samples.
+func (d *ExponentiallyDecayingDigest) Count() float64 {
+	return float64(d.digest.Count()) / decayResolution / d.factor(d.now())
+}
+
+// How much heavier a sample registered at the given time is than one
+// registered at the reference time.
+func (d *ExponentiallyDecayingDigest) factor(at time.Time) float64 {
+	return math.Exp(d.lambda * at.Sub(d.reference).Seconds())
+}
+
+// Moves the reference time to now, scaling all counts accordingly
+// and discarding the centroids that become negligible.
+func (d *ExponentiallyDecayingDigest) rescale() {
+	now := d.now()
+	factor := d.factor(now)
+	d.reference = now
+
+	s := d.digest.summary
+	kept := 0
+	d.digest.count = 0
+	for i, count := range s.counts {
+		scaled := uint64(math.Round(float64(count) / factor))
+		if scaled == 0 {
+			continue
+		}
+		s.means[kept] = s.means[i]
+		s.counts[kept] = scaled
+		d.digest.count += scaled
+		kept++
+	}
+	s.means = s.means[:kept]
+	s.counts = s.counts[:kept]
+}
@@ -0,0 +1,113 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func newTestDecaying(t *testing.T, clock *fakeClock, halfLife time.Duration) *ExponentiallyDecayingDigest {
+	d, err := NewDecaying(halfLife)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.now = clock.Now
+	d.reference = clock.Now()
+	return d
+}
+
+func TestExponentiallyDecayingDigest(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	d := newTestDecaying(t, clock, 10*time.Minute)
+
+	for i := 0; i < 1000; i++ {
+		_ = d.Add(0)
+	}
+
+	if math.Abs(d.Count()-1000) > 1e-9 {
+		t.Errorf("Expected count 1000, got %f", d.Count())
+	}
+
+	clock.Advance(time.Hour)
+	if math.Abs(d.Count()-1000.0/64) > 1e-9 {
+		t.Errorf("Expected count to decay to 1000/64, got %f", d.Count())
+	}
+
+	for i := 0; i < 1000; i++ {
+		_ = d.Add(1)
+	}
+
+	// Samples from an hour ago weigh 1/64th of the fresh ones
+	expected := (1000.0 / 64) / (1000.0/64 + 1000)
+	if cdf := d.CDF(0.5); math.Abs(cdf-expected) > 0.001 {
+		t.Errorf("Expected CDF(0.5) close to %f, got %f", expected, cdf)
+	}
+
+	if q := d.Quantile(0.5); q != 1 {
+		t.Errorf("Expected the median to be 1, got %f", q)
+	}
+
+	if d.AddWeighted(1, 0) == nil || d.Add(math.NaN()) == nil {
+		t.Errorf("Expected errors for invalid samples")
+	}
+
+	if _, err := NewDecaying(0); err == nil {
+		t.Errorf("Expected error for a zero half-life")
+	}
+}
+
+func TestExponentiallyDecayingDigestRescale(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	d := newTestDecaying(t, clock, time.Minute)
+
+	// Way past the point in which the weights must be rescaled
+	for m := 0; m < 120; m++ {
+		for i := 0; i < 100; i++ {
+			_ = d.Add(rng.Float64())
+		}
+		clock.Advance(time.Minute)
+	}
+
+	// The weights form a geometric series: 100 * (1/2 + 1/4 + ...)
+	if math.Abs(d.Count()-100) > 1 {
+		t.Errorf("Expected count close to 100, got %f", d.Count())
+	}
+
+	if d.digest.count > math.MaxUint64/2 || d.digest.Validate() != nil {
+		t.Errorf("Expected the counts to be kept in check")
+	}
+
+	if q := d.Quantile(0.5); math.Abs(q-0.5) > 0.1 {
+		t.Errorf("Expected the median close to 0.5, got %f", q)
+	}
+}
+
+func TestExponentiallyDecayingDigestMerge(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	old := newTestDecaying(t, clock, 10*time.Minute)
+	for i := 0; i < 1000; i++ {
+		_ = old.Add(0)
+	}
+
+	clock.Advance(time.Hour)
+	fresh := newTestDecaying(t, clock, 10*time.Minute)
+	for i := 0; i < 1000; i++ {
+		_ = fresh.Add(1)
+	}
+
+	err := fresh.Merge(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(fresh.Count()-(1000+1000.0/64)) > 0.01 {
+		t.Errorf("Expected the merged count to account for the decay, got %f", fresh.Count())
+	}
+
+	expected := (1000.0 / 64) / (1000.0/64 + 1000)
+	if cdf := fresh.CDF(0.5); math.Abs(cdf-expected) > 0.001 {
+		t.Errorf("Expected CDF(0.5) close to %f, got %f", expected, cdf)
+	}
+}
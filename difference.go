@@ -0,0 +1,61 @@
+package tdigest
+
+import (
+	"fmt"
+	"math"
+)
+
+// Difference creates a new digest approximating the samples that are
+// in digest a but not in digest b.
+//
+// Every centroid in a is assumed to cover the values from halfway to
+// its previous neighbor up to halfway to its next one (the first and
+// last centroids extend to infinity). The number of samples b holds
+// within that range, estimated via b.CDF, is subtracted from the
+// centroid count, carrying over to the next centroids whatever
+// couldn't be subtracted. Centroids whose count drops to zero are
+// discarded.
+//
+// This is inherently approximate: the subtraction happens at the
+// resolution of a's centroids and b's samples are assumed to be
+// distributed like b's CDF interpolation suggests, so the result is
+// only meaningful when b represents (roughly) a subset of a - for
+// example, when a was obtained by merging b into another digest.
+// Subtracting a digest that is not contained in a simply removes
+// mass from the closest centroids of a.
+//
+// This will emit an error if either digest is empty.
+func Difference(a, b *TDigest) (*TDigest, error) {
+	if a.summary.Len() == 0 || b.summary.Len() == 0 {
+		return nil, fmt.Errorf("can't compute the difference of empty digests")
+	}
+
+	result, err := New(Compression(a.compression))
+	if err != nil {
+		return nil, err
+	}
+
+	lower, debt := 0.0, 0.0
+	for i, mean := range a.summary.means {
+		upper := 1.0
+		if i+1 < a.summary.Len() {
+			upper = b.CDF((mean + a.summary.Mean(i+1)) / 2)
+		}
+
+		// Whatever couldn't be subtracted from the previous centroids
+		// is subtracted from the next ones
+		remaining := float64(a.summary.Count(i)) - (upper-lower)*float64(b.count) - debt
+		lower = upper
+		debt = math.Max(-remaining, 0)
+
+		count := uint64(math.Round(math.Max(remaining, 0)))
+		if count == 0 {
+			continue
+		}
+
+		result.summary.means = append(result.summary.means, mean)
+		result.summary.counts = append(result.summary.counts, count)
+		result.count += count
+	}
+	return result, nil
+}
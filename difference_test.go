@@ -0,0 +1,59 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestDifference(t *testing.T) {
+	if _, err := Difference(uncheckedNew(), uncheckedNew()); err == nil {
+		t.Errorf("Expected Difference() to error out on empty digests")
+	}
+
+	tests := []struct {
+		name         string
+		offset       float64
+		maxDiffCount float64
+		maxDiffValue float64
+	}{
+		{"disjoint", 2, 0.01, 0.01},
+		{"overlapping", 0.5, 0.05, 0.05},
+	}
+
+	for _, test := range tests {
+		rnd := rand.New(rand.NewSource(0xCA10))
+		a := uncheckedNew()
+		b := uncheckedNew()
+
+		data := make([]float64, 10000)
+		for i := range data {
+			data[i] = rnd.Float64()
+			_ = a.Add(data[i])
+			_ = b.Add(test.offset + rnd.Float64())
+		}
+		sort.Float64s(data)
+
+		merged := a.Clone()
+		if err := merged.Merge(b); err != nil {
+			t.Fatal(err)
+		}
+
+		diff, err := Difference(merged, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		countError := math.Abs(float64(diff.Count())-float64(a.Count())) / float64(a.Count())
+		if countError > test.maxDiffCount {
+			t.Errorf("%s: expected count close to %d, got %d", test.name, a.Count(), diff.Count())
+		}
+
+		for _, q := range []float64{0.1, 0.25, 0.5, 0.75, 0.9} {
+			if got, wanted := diff.Quantile(q), quantile(q, data); math.Abs(got-wanted) > test.maxDiffValue {
+				t.Errorf("%s: expected Quantile(%.2f) close to %.4f, got %.4f", test.name, q, wanted, got)
+			}
+		}
+	}
+}
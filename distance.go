@@ -0,0 +1,221 @@
+package tdigest
+
+import (
+	"math"
+	"sort"
+)
+
+// KLDivergence estimates the Kullback-Leibler divergence KL(t || other)
+// in nats: how much information is lost when other is used to
+// approximate this digest. It's zero for identical distributions and
+// grows as they drift apart, which makes it useful for detecting
+// distribution drift between two windows of a stream.
+//
+// Both digests are discretized into the intervals between the union
+// of their centroid means (plus the tails below and above them) and
+// the probability of each interval is estimated from CDF differences.
+// Since digests only approximate the distributions, so does the
+// result: it is best used to compare digests with a similar number of
+// samples and the same compression.
+//
+// This returns +Inf if other has no mass in an interval where this
+// digest has some (e.g.: disjoint distributions) and NaN if either
+// digest is empty. Since digests have no mass beyond their extremes,
+// the result is also +Inf whenever the minimum or maximum of this
+// digest falls outside the range of other, even if both come from the
+// same distribution: compare against a digest that covers the range
+// of the values of interest, such as a long-lived baseline.
+func (t *TDigest) KLDivergence(other *TDigest) float64 {
+	if t.summary.Len() == 0 || other.summary.Len() == 0 {
+		return math.NaN()
+	}
+
+	points := unionOfMeans(t, other)
+	p := intervalMasses(t.CDFs(points))
+	q := intervalMasses(other.CDFs(points))
+
+	var divergence float64
+	for i := range p {
+		if p[i] <= 0 {
+			continue
+		}
+		if q[i] <= 0 {
+			return math.Inf(1)
+		}
+		divergence += p[i] * math.Log(p[i]/q[i])
+	}
+
+	// Rounding errors may lead to tiny negative results
+	return math.Max(divergence, 0)
+}
+
+// WassersteinDistance estimates the Wasserstein-1 (earth mover's)
+// distance between this digest and other: the integral of the absolute
+// difference between their CDFs. It's expressed in the same unit as
+// the samples and can be read as the average distance each sample must
+// move to turn one distribution into the other, e.g.: shifting every
+// sample by 5 yields a distance of 5.
+//
+// Unlike KLDivergence, the distance is symmetric and finite for
+// distributions with disjoint supports, which makes it a robust
+// measure of dataset shift. The integral is approximated over the
+// intervals between the union of the centroid means of both digests,
+// evaluating the CDFs in the middle of each interval: unlike the
+// trapezoid rule, this is exact for the steps that digests with few
+// centroids have at their means.
+//
+// This returns NaN if either digest is empty.
+func (t *TDigest) WassersteinDistance(other *TDigest) float64 {
+	if t.summary.Len() == 0 || other.summary.Len() == 0 {
+		return math.NaN()
+	}
+
+	points := unionOfMeans(t, other)
+	if len(points) < 2 {
+		return 0
+	}
+
+	midpoints := make([]float64, len(points)-1)
+	for i := range midpoints {
+		midpoints[i] = points[i] + (points[i+1]-points[i])/2
+	}
+	a := t.CDFs(midpoints)
+	b := other.CDFs(midpoints)
+
+	var distance float64
+	for i := range midpoints {
+		distance += math.Abs(a[i]-b[i]) * (points[i+1] - points[i])
+	}
+	return distance
+}
+
+// DiffResult summarizes how the key percentiles changed between two
+// digests, as computed by Diff. All differences are the value for the
+// second digest minus the value for the first.
+type DiffResult struct {
+	P50Diff, P90Diff, P99Diff float64
+	CountDiff                 int64
+
+	// The largest percentile difference relative to the value in the
+	// first digest, e.g.: 0.1 if a percentile grew (or shrunk) by 10%
+	RelativeDiff float64
+}
+
+// Diff compares the percentiles of digest b with the ones of digest a,
+// which is handy for spotting regressions: a is usually the baseline
+// and b the candidate.
+//
+// Returns a zero DiffResult if both digests are empty. If only one of
+// them is, the percentile differences are NaN.
+func Diff(a, b *TDigest) DiffResult {
+	if a.summary.Len() == 0 && b.summary.Len() == 0 {
+		return DiffResult{}
+	}
+
+	qs := []float64{0.5, 0.9, 0.99}
+	before := a.Quantiles(qs)
+	after := b.Quantiles(qs)
+
+	diffs := make([]float64, len(qs))
+	relative := 0.0
+	for i := range qs {
+		diffs[i] = after[i] - before[i]
+		if diffs[i] != 0 {
+			relative = math.Max(relative, math.Abs(diffs[i]/before[i]))
+		}
+	}
+	if math.IsNaN(diffs[0]) {
+		relative = math.NaN()
+	}
+
+	return DiffResult{
+		P50Diff:      diffs[0],
+		P90Diff:      diffs[1],
+		P99Diff:      diffs[2],
+		CountDiff:    int64(b.count - a.count),
+		RelativeDiff: relative,
+	}
+}
+
+// IsSignificant tells whether the absolute difference of any of the
+// percentiles exceeds threshold. NaN differences (i.e.: only one of
+// the digests was empty) are always significant.
+func (d DiffResult) IsSignificant(threshold float64) bool {
+	for _, diff := range []float64{d.P50Diff, d.P90Diff, d.P99Diff} {
+		if !(math.Abs(diff) <= threshold) {
+			return true
+		}
+	}
+	return false
+}
+
+// The number of quantiles evaluated by AndersonDarlingStatistic
+const andersonDarlingPoints = 100
+
+// AndersonDarlingStatistic computes the Anderson-Darling statistic A²
+// for the hypothesis that the samples follow a normal distribution
+// with the given mean and standard deviation. Larger values indicate
+// a worse fit: as a rule of thumb, values above 2.5 reject normality
+// at the 5% significance level.
+//
+// This is an approximation: since digests don't hold the samples, the
+// statistic is computed over 100 evenly spaced quantile estimations,
+// as if those were the samples. It thus behaves like the test of a
+// sample of size 100, regardless of the number of samples in the
+// digest, so it's suitable as a quick check rather than a rigorous
+// test.
+//
+// Returns NaN if the digest has less than 2 samples or if stddev is
+// not positive.
+func (t *TDigest) AndersonDarlingStatistic(mean, stddev float64) float64 {
+	if t.count < 2 || !(stddev > 0) || math.IsNaN(mean) {
+		return math.NaN()
+	}
+
+	const n = andersonDarlingPoints
+	qs := make([]float64, n)
+	for i := range qs {
+		qs[i] = (float64(i) + 0.5) / n
+	}
+
+	phi := make([]float64, n)
+	for i, x := range t.Quantiles(qs) {
+		phi[i] = 0.5 * math.Erfc(-(x-mean)/(stddev*math.Sqrt2))
+	}
+
+	var sum float64
+	for i := 0; i < n; i++ {
+		sum += float64(2*i+1) * (math.Log(phi[i]) + math.Log(1-phi[n-1-i]))
+	}
+	return -n - sum/n
+}
+
+// Returns the sorted, deduplicated means of the centroids of both
+// digests
+func unionOfMeans(a, b *TDigest) []float64 {
+	points := make([]float64, 0, a.summary.Len()+b.summary.Len())
+	points = append(points, a.summary.means...)
+	points = append(points, b.summary.means...)
+	sort.Float64s(points)
+
+	unique := points[:0]
+	for i, point := range points {
+		if i == 0 || point != points[i-1] {
+			unique = append(unique, point)
+		}
+	}
+	return unique
+}
+
+// Converts the CDF evaluated at sorted points into the mass of the
+// intervals they delimit, including both tails.
+func intervalMasses(cdfs []float64) []float64 {
+	masses := make([]float64, len(cdfs)+1)
+	prev := 0.0
+	for i, cdf := range cdfs {
+		masses[i] = cdf - prev
+		prev = cdf
+	}
+	masses[len(cdfs)] = 1 - prev
+	return masses
+}
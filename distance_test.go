@@ -0,0 +1,179 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestKLDivergence(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	a := uncheckedNew()
+	b := uncheckedNew()
+	shifted := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = a.Add(rng.NormFloat64())
+		_ = b.Add(rng.NormFloat64())
+		_ = shifted.Add(rng.NormFloat64() + 100)
+	}
+
+	if kl := a.KLDivergence(a.Clone()); math.Abs(kl) > 1e-9 {
+		t.Errorf("Expected KL close to 0 for identical digests, got %f", kl)
+	}
+
+	if kl := a.KLDivergence(b); kl > 0.1 {
+		t.Errorf("Expected a small KL for samples of the same distribution, got %f", kl)
+	}
+
+	if kl := a.KLDivergence(shifted); !math.IsInf(kl, 1) {
+		t.Errorf("Expected KL to be +Inf for separated digests, got %f", kl)
+	}
+
+	// KL(U(0.4, 0.6) || U(0, 1)) = log(5)
+	narrow := uncheckedNew()
+	wide := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = narrow.Add(0.4 + 0.2*rng.Float64())
+		_ = wide.Add(rng.Float64())
+	}
+
+	if kl := narrow.KLDivergence(wide); kl < 1 || math.Abs(kl-math.Log(5)) > 0.2 {
+		t.Errorf("Expected KL close to %f, got %f", math.Log(5), kl)
+	}
+
+	if !math.IsNaN(a.KLDivergence(uncheckedNew())) || !math.IsNaN(uncheckedNew().KLDivergence(a)) {
+		t.Errorf("Expected NaN when either digest is empty")
+	}
+}
+
+func TestWassersteinDistance(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	a := uncheckedNew()
+	b := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = a.Add(rng.NormFloat64())
+		_ = b.Add(rng.NormFloat64())
+	}
+
+	if d := a.WassersteinDistance(a.Clone()); d != 0 {
+		t.Errorf("Expected 0 for identical digests, got %f", d)
+	}
+
+	if d := a.WassersteinDistance(b); d > 0.05 {
+		t.Errorf("Expected a small distance for samples of the same distribution, got %f", d)
+	}
+
+	for _, delta := range []float64{0.5, 5, 1000} {
+		shifted := a.Clone()
+		_ = shifted.Shift(delta)
+
+		// Disjoint supports from a delta of 5 onwards
+		d := a.WassersteinDistance(shifted)
+		if math.Abs(d-delta) > 0.05*delta {
+			t.Errorf("Expected a distance close to %f, got %f", delta, d)
+		}
+
+		if d != shifted.WassersteinDistance(a) {
+			t.Errorf("Expected the distance to be symmetric")
+		}
+	}
+
+	single := uncheckedNew()
+	_ = single.Add(10)
+	other := uncheckedNew()
+	_ = other.Add(3)
+	if d := single.WassersteinDistance(other); d != 7 {
+		t.Errorf("Expected a distance of 7 between single points, got %f", d)
+	}
+
+	if !math.IsNaN(a.WassersteinDistance(uncheckedNew())) || !math.IsNaN(uncheckedNew().WassersteinDistance(a)) {
+		t.Errorf("Expected NaN when either digest is empty")
+	}
+}
+
+func TestAndersonDarlingStatistic(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	normal := uncheckedNew()
+	exponential := uncheckedNew()
+	for i := 0; i < 100000; i++ {
+		_ = normal.Add(10 + 2*rng.NormFloat64())
+		_ = exponential.Add(rng.ExpFloat64())
+	}
+
+	if a2 := normal.AndersonDarlingStatistic(10, 2); a2 > 1 {
+		t.Errorf("Expected a small A² for normal samples, got %f", a2)
+	}
+
+	if a2 := normal.AndersonDarlingStatistic(12, 2); a2 < 2.5 {
+		t.Errorf("Expected a large A² for the wrong mean, got %f", a2)
+	}
+
+	// Same mean and standard deviation, but clearly not normal
+	if a2 := exponential.AndersonDarlingStatistic(1, 1); a2 < 2.5 {
+		t.Errorf("Expected a large A² for exponential samples, got %f", a2)
+	}
+
+	single := uncheckedNew()
+	_ = single.Add(1)
+	for _, a2 := range []float64{
+		uncheckedNew().AndersonDarlingStatistic(0, 1),
+		single.AndersonDarlingStatistic(0, 1),
+		normal.AndersonDarlingStatistic(10, 0),
+		normal.AndersonDarlingStatistic(math.NaN(), 1),
+	} {
+		if !math.IsNaN(a2) {
+			t.Errorf("Expected NaN, got %f", a2)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	baseline := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = baseline.Add(100 + rng.Float64()*100)
+	}
+
+	// Same shape, 10% slower and with twice the samples
+	candidate := baseline.Clone()
+	_ = candidate.Merge(baseline)
+	_ = candidate.Scale(1.1)
+
+	diff := Diff(baseline, candidate)
+	if diff.CountDiff != 10000 {
+		t.Errorf("Expected a count difference of 10000, got %d", diff.CountDiff)
+	}
+
+	for i, q := range []float64{0.5, 0.9, 0.99} {
+		got := []float64{diff.P50Diff, diff.P90Diff, diff.P99Diff}[i]
+		expected := baseline.Quantile(q) * 0.1
+		if math.Abs(got-expected) > 0.01*expected {
+			t.Errorf("Expected the difference at q=%.2f to be close to %f, got %f", q, expected, got)
+		}
+	}
+
+	if math.Abs(diff.RelativeDiff-0.1) > 0.001 {
+		t.Errorf("Expected a relative difference close to 0.1, got %f", diff.RelativeDiff)
+	}
+
+	if !diff.IsSignificant(10) || diff.IsSignificant(25) {
+		t.Errorf("Expected differences between 15 and 20 to be significant only for smaller thresholds")
+	}
+
+	if reverse := Diff(candidate, baseline); reverse.P99Diff >= 0 || reverse.CountDiff != -10000 {
+		t.Errorf("Expected negative differences in the opposite direction, got %+v", reverse)
+	}
+
+	if same := Diff(baseline, baseline); same != (DiffResult{}) || same.IsSignificant(0) {
+		t.Errorf("Expected no difference between a digest and itself, got %+v", same)
+	}
+
+	if empty := Diff(uncheckedNew(), uncheckedNew()); empty != (DiffResult{}) {
+		t.Errorf("Expected a zero result for empty digests, got %+v", empty)
+	}
+
+	oneEmpty := Diff(uncheckedNew(), baseline)
+	if !math.IsNaN(oneEmpty.P50Diff) || !oneEmpty.IsSignificant(math.MaxFloat64) {
+		t.Errorf("Expected NaN differences to be significant, got %+v", oneEmpty)
+	}
+}
@@ -0,0 +1,72 @@
+package tdigest
+
+// FixedSizeDigest is a digest with a hard upper bound on the number
+// of centroids it holds.
+//
+// It's a thin wrapper over a digest configured with the MaxCentroids
+// option, which documents the merge policy and its trade-offs.
+type FixedSizeDigest struct {
+	digest *TDigest
+}
+
+// NewFixedSizeDigest creates a digest that never holds more than
+// maxCentroids centroids after an addition. The options configure
+// the underlying digest like in New.
+//
+// This will emit an error if maxCentroids < 1 or if the options are
+// invalid.
+func NewFixedSizeDigest(maxCentroids int, options ...tdigestOption) (*FixedSizeDigest, error) {
+	// Last, so that it takes precedence over any MaxCentroids option
+	options = append(options[:len(options):len(options)], MaxCentroids(maxCentroids))
+	digest, err := New(options...)
+	if err != nil {
+		return nil, err
+	}
+	return &FixedSizeDigest{digest: digest}, nil
+}
+
+// AddWeighted registers a new sample in the digest, merging the
+// nearest centroids if the bound is exceeded. Refer to
+// TDigest.AddWeighted for more details.
+func (f *FixedSizeDigest) AddWeighted(value float64, count uint64) error {
+	return f.digest.AddWeighted(value, count)
+}
+
+// Add is an alias for AddWeighted(x,1)
+func (f *FixedSizeDigest) Add(value float64) error {
+	return f.AddWeighted(value, 1)
+}
+
+// Quantile returns the desired percentile estimation. Refer to
+// TDigest.Quantile for more details.
+func (f *FixedSizeDigest) Quantile(q float64) float64 {
+	return f.digest.Quantile(q)
+}
+
+// CDF computes the fraction in which all samples are less than
+// or equal to the given value.
+func (f *FixedSizeDigest) CDF(value float64) float64 {
+	return f.digest.CDF(value)
+}
+
+// Count returns the total number of samples this digest represents.
+func (f *FixedSizeDigest) Count() uint64 {
+	return f.digest.Count()
+}
+
+// Len returns the number of centroids in the digest, which is never
+// greater than MaxCentroids.
+func (f *FixedSizeDigest) Len() int {
+	return f.digest.Len()
+}
+
+// MaxCentroids returns the bound on the number of centroids.
+func (f *FixedSizeDigest) MaxCentroids() int {
+	return f.digest.maxCentroids
+}
+
+// ForEachCentroid calls the specified function for each centroid.
+// Refer to TDigest.ForEachCentroid for more details.
+func (f *FixedSizeDigest) ForEachCentroid(fn func(mean float64, count uint64) bool) {
+	f.digest.ForEachCentroid(fn)
+}
@@ -0,0 +1,56 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFixedSizeDigest(t *testing.T) {
+	if _, err := NewFixedSizeDigest(0); err == nil {
+		t.Errorf("Expected an error with maxCentroids=0")
+	}
+
+	if _, err := NewFixedSizeDigest(10, Compression(0)); err == nil {
+		t.Errorf("Expected an error with invalid options")
+	}
+
+	digest, err := NewFixedSizeDigest(50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rnd := rand.New(rand.NewSource(0xCA10))
+	for i := 0; i < 1000000; i++ {
+		err := digest.Add(rnd.Float64())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if digest.Len() > digest.MaxCentroids() {
+			t.Fatalf("Expected at most %d centroids, got %d after %d additions",
+				digest.MaxCentroids(), digest.Len(), i+1)
+		}
+	}
+
+	if digest.Count() != 1000000 {
+		t.Errorf("Expected count to be 1000000, got %d", digest.Count())
+	}
+
+	var total uint64
+	digest.ForEachCentroid(func(mean float64, count uint64) bool {
+		total += count
+		return true
+	})
+	if total != digest.Count() {
+		t.Errorf("Merging centroids should preserve the count. Got %d", total)
+	}
+
+	for _, q := range []float64{0.1, 0.5, 0.9} {
+		if math.Abs(digest.Quantile(q)-q) > 0.02 {
+			t.Errorf("Expected Quantile(%.1f) close to %.1f, got %.4f", q, q, digest.Quantile(q))
+		}
+		if math.Abs(digest.CDF(q)-q) > 0.02 {
+			t.Errorf("Expected CDF(%.1f) close to %.1f, got %.4f", q, q, digest.CDF(q))
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package tdigest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Maximum number of centroids written by GoString.
+const goStringMaxCentroids = 50
+
+// GoString implements fmt.GoStringer, making `%#v` print a Go
+// expression that recreates the digest via NewFromCentroids.
+//
+// This lets a failing test print a digest that can be pasted into a
+// regression test. Only the first 50 centroids are written: for larger
+// digests a comment noting the number of omitted centroids is added
+// and the expression creates a truncated digest.
+func (t *TDigest) GoString() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "tdigest.NewFromCentroids(%s, []tdigest.Centroid{", formatFloat(t.compression))
+	for i := 0; i < t.summary.Len() && i < goStringMaxCentroids; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "{Mean: %s, Count: %d}", formatFloat(t.summary.Mean(i)), t.summary.Count(i))
+	}
+	if omitted := t.summary.Len() - goStringMaxCentroids; omitted > 0 {
+		fmt.Fprintf(&b, " /* %d more centroids (count=%d) omitted */", omitted, t.count)
+	}
+	b.WriteString("})")
+
+	return b.String()
+}
+
+// Quantiles reported by Describe.
+var describeQuantiles = []struct {
+	name string
+	q    float64
+}{
+	{"p25", 0.25},
+	{"p50", 0.5},
+	{"p75", 0.75},
+	{"p90", 0.9},
+	{"p95", 0.95},
+	{"p99", 0.99},
+	{"p999", 0.999},
+}
+
+// Describe returns a multi-line human readable description of the
+// digest, meant for debugging.
+//
+// It includes the compression, count, number of centroids and, for
+// non-empty digests, the min, max, mean, (approximate) standard
+// deviation and a few quantile estimations. The format is stable, so
+// it's suitable for golden file tests. Describe walks the centroids
+// several times: avoid it on hot paths.
+func (t *TDigest) Describe() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "compression: %g\n", t.compression)
+	fmt.Fprintf(&b, "count:       %d\n", t.count)
+	fmt.Fprintf(&b, "centroids:   %d\n", t.summary.Len())
+
+	if t.summary.Len() == 0 {
+		return b.String()
+	}
+
+	mean := t.Mean()
+	fmt.Fprintf(&b, "min:         %.6g\n", t.Min())
+	fmt.Fprintf(&b, "max:         %.6g\n", t.Max())
+	fmt.Fprintf(&b, "mean:        %.6g\n", mean)
+	fmt.Fprintf(&b, "stddev:      %.6g\n", math.Sqrt(t.variance(mean)))
+	for _, dq := range describeQuantiles {
+		fmt.Fprintf(&b, "%-13s%.6g\n", dq.name+":", t.Quantile(dq.q))
+	}
+
+	return b.String()
+}
+
+// Visualize returns an ASCII bar chart of the centroids, meant for
+// debugging. Each line shows the mean of a centroid, a bar of up to
+// width characters proportional to its count (relative to the largest
+// centroid) and the count itself:
+//
+//	0.25 |########## 10
+//	 0.5 |##### 5
+//
+// Notice that this shows how the digest is structured, not the shape
+// of the distribution: by design, centroids around the median hold
+// more samples than the ones at the tails. Use PDF for the latter.
+//
+// Returns an empty string for empty digests. Values of width must be
+// >= 1, will panic otherwise.
+func (t *TDigest) Visualize(width int) string {
+	if width < 1 {
+		panic("width must be >= 1")
+	}
+
+	if t.summary.Len() == 0 {
+		return ""
+	}
+
+	labels := make([]string, t.summary.Len())
+	labelWidth := 0
+	var maxCount uint64
+	for i, mean := range t.summary.means {
+		labels[i] = strconv.FormatFloat(mean, 'g', 6, 64)
+		if len(labels[i]) > labelWidth {
+			labelWidth = len(labels[i])
+		}
+		if t.summary.counts[i] > maxCount {
+			maxCount = t.summary.counts[i]
+		}
+	}
+
+	var b strings.Builder
+	for i, count := range t.summary.counts {
+		// Every centroid gets a bar, however small
+		length := int(math.Max(1, math.Round(float64(count)/float64(maxCount)*float64(width))))
+		fmt.Fprintf(&b, "%*s |%s %d\n", labelWidth, labels[i], strings.Repeat("#", length), count)
+	}
+	return b.String()
+}
@@ -0,0 +1,229 @@
+package tdigest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Recreates a digest from the output of GoString by evaluating the
+// centroid literals it contains.
+func evalGoString(t *testing.T, s string) *TDigest {
+	expr, err := parser.ParseExpr(s)
+	if err != nil {
+		t.Fatalf("GoString() is not a valid expression: %s\n%s", err, s)
+	}
+
+	call := expr.(*ast.CallExpr)
+	if fun := call.Fun.(*ast.SelectorExpr); fun.X.(*ast.Ident).Name != "tdigest" || fun.Sel.Name != "NewFromCentroids" {
+		t.Fatalf("Expected a call to tdigest.NewFromCentroids, got %s", s)
+	}
+
+	compression := parseGoFloat(t, call.Args[0])
+
+	var centroids []Centroid
+	for _, elt := range call.Args[1].(*ast.CompositeLit).Elts {
+		var c Centroid
+		for _, field := range elt.(*ast.CompositeLit).Elts {
+			kv := field.(*ast.KeyValueExpr)
+			switch kv.Key.(*ast.Ident).Name {
+			case "Mean":
+				c.Mean = parseGoFloat(t, kv.Value)
+			case "Count":
+				c.Count, err = strconv.ParseUint(kv.Value.(*ast.BasicLit).Value, 10, 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		centroids = append(centroids, c)
+	}
+
+	digest, err := NewFromCentroids(compression, centroids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digest
+}
+
+func parseGoFloat(t *testing.T, expr ast.Expr) float64 {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		f, err := strconv.ParseFloat(e.Value, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	case *ast.UnaryExpr:
+		if e.Op == token.SUB {
+			return -parseGoFloat(t, e.X)
+		}
+	}
+	t.Fatalf("Unexpected expression %#v", expr)
+	return 0
+}
+
+func equalWithin(a, b *TDigest, epsilon float64) bool {
+	if a.Compression() != b.Compression() || a.summary.Len() != b.summary.Len() {
+		return false
+	}
+	for _, q := range []float64{0, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 1} {
+		if math.Abs(a.Quantile(q)-b.Quantile(q)) > epsilon {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGoString(t *testing.T) {
+	tdigest := uncheckedNew(Compression(10))
+	for _, x := range []float64{0.5, -1.25, 3, 1e-9} {
+		_ = tdigest.AddWeighted(x, 10)
+	}
+
+	wanted := "tdigest.NewFromCentroids(10, []tdigest.Centroid{{Mean: -1.25, Count: 10}, {Mean: 1e-09, Count: 10}, {Mean: 0.5, Count: 10}, {Mean: 3, Count: 10}})"
+	if got := fmt.Sprintf("%#v", tdigest); got != wanted {
+		t.Fatalf("Unexpected GoString():\n%s\nwanted:\n%s", got, wanted)
+	}
+
+	rnd := rand.New(rand.NewSource(0xCA10))
+	for i := 0; i < 30; i++ {
+		_ = tdigest.Add(rnd.NormFloat64())
+	}
+
+	if tdigest.summary.Len() > goStringMaxCentroids {
+		t.Fatalf("Expected a small digest, got %d centroids", tdigest.summary.Len())
+	}
+
+	restored := evalGoString(t, tdigest.GoString())
+	if !equalWithin(tdigest, restored, 0) || restored.Count() != tdigest.Count() {
+		t.Errorf("GoString() should recreate the digest exactly")
+	}
+}
+
+func TestGoStringTruncates(t *testing.T) {
+	tdigest := uncheckedNew()
+	for i := 0; i < 100; i++ {
+		_ = tdigest.Add(float64(i))
+	}
+
+	s := tdigest.GoString()
+	if !strings.Contains(s, "/* 50 more centroids (count=100) omitted */") {
+		t.Errorf("Expected GoString() to note the omitted centroids. Got %s", s)
+	}
+
+	restored := evalGoString(t, s)
+	if restored.summary.Len() != goStringMaxCentroids {
+		t.Errorf("Expected a truncated digest with %d centroids, got %d", goStringMaxCentroids, restored.summary.Len())
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tdigest := uncheckedNew()
+
+	wanted := `compression: 100
+count:       0
+centroids:   0
+`
+	if got := tdigest.Describe(); got != wanted {
+		t.Errorf("Unexpected Describe():\n%s\nwanted:\n%s", got, wanted)
+	}
+
+	for i := 1; i <= 1000; i++ {
+		_ = tdigest.Add(float64(i))
+	}
+
+	wanted = `compression: 100
+count:       1000
+centroids:   1000
+min:         1
+max:         1000
+mean:        500.5
+stddev:      288.675
+p25:         250.75
+p50:         500.5
+p75:         750.25
+p90:         900.1
+p95:         950.05
+p99:         990.01
+p999:        999.001
+`
+	got := tdigest.Describe()
+	if got != wanted {
+		t.Fatalf("Unexpected Describe():\n%s\nwanted:\n%s", got, wanted)
+	}
+
+	// Quantiles match direct calls
+	lines := strings.Split(got, "\n")
+	for i, dq := range describeQuantiles {
+		fields := strings.Fields(lines[7+i])
+		if fields[0] != dq.name+":" {
+			t.Fatalf("Expected %s, got %s", dq.name, fields[0])
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wanted := tdigest.Quantile(dq.q); math.Abs(value-wanted) > 1e-5*wanted {
+			t.Errorf("Expected %s = %f, got %f", dq.name, wanted, value)
+		}
+	}
+}
+
+func TestVisualize(t *testing.T) {
+	if got := uncheckedNew().Visualize(10); got != "" {
+		t.Errorf("Expected an empty string for an empty digest, got %q", got)
+	}
+
+	// Two groups of heavy centroids separated by light ones
+	counts := []uint64{1, 5, 10, 5, 1, 1, 4, 8, 4, 1}
+	centroids := make([]Centroid, len(counts))
+	for i, count := range counts {
+		centroids[i] = Centroid{Mean: float64(i) * 0.5, Count: count}
+	}
+	tdigest, err := NewFromCentroids(100, centroids)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wanted := `  0 |# 1
+0.5 |##### 5
+  1 |########## 10
+1.5 |##### 5
+  2 |# 1
+2.5 |# 1
+  3 |#### 4
+3.5 |######## 8
+  4 |#### 4
+4.5 |# 1
+`
+	got := tdigest.Visualize(10)
+	if got != wanted {
+		t.Fatalf("Unexpected Visualize():\n%s\nwanted:\n%s", got, wanted)
+	}
+
+	var bars []int
+	for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+		bars = append(bars, strings.Count(line, "#"))
+	}
+
+	var peaks []int
+	for i := 1; i < len(bars)-1; i++ {
+		if bars[i] > bars[i-1] && bars[i] > bars[i+1] {
+			peaks = append(peaks, i)
+		}
+	}
+	if len(peaks) != 2 || bars[(peaks[0]+peaks[1])/2] >= bars[peaks[1]] {
+		t.Errorf("Expected two peaks separated by shorter bars, got %v", bars)
+	}
+
+	shouldPanic(func() {
+		tdigest.Visualize(0)
+	}, t, "Visualize with width 0 should panic!")
+}
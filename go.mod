@@ -4,5 +4,7 @@ go 1.18
 
 require (
 	github.com/leesper/go_rng v0.0.0-20190531154944-a612b043e353
+	github.com/prometheus/client_model v0.4.0
 	gonum.org/v1/gonum v0.11.0
+	google.golang.org/protobuf v1.30.0
 )
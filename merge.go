@@ -0,0 +1,200 @@
+package tdigest
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// ConcurrentMerge merges the given digests into a new one, using up
+// to `workers` goroutines.
+//
+// The digests are combined as a tree-reduce: on every round they are
+// paired up and each pair is merged in parallel, until a single
+// digest remains. This is much faster than merging every digest
+// sequentially into a single one when combining a large number of
+// digests, e.g.: the per-shard digests of a map-reduce job.
+//
+// The input digests are only read, so they are left intact. They must
+// not be modified while ConcurrentMerge is running though. Both the
+// result and all the intermediary digests are created with the given
+// options: if you configure a custom RNG via RandomNumberGenerator it
+// must be safe for concurrent use.
+//
+// Workers must be greater than 0, will yield an error otherwise. An
+// error is also returned if any of the merges fail.
+func ConcurrentMerge(digests []*TDigest, workers int, options ...tdigestOption) (*TDigest, error) {
+	if workers <= 0 {
+		return nil, fmt.Errorf("workers must be > 0, got %d", workers)
+	}
+
+	// Validates the options upfront so that failures don't surface
+	// from within the workers
+	result, err := New(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(digests) {
+	case 0:
+		return result, nil
+	case 1:
+		err = result.Merge(digests[0])
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, workers)
+
+	level := digests
+	// The first round merges into fresh digests; Subsequent rounds
+	// only see digests created here, so they can be merged in place.
+	owned := false
+	for len(level) > 1 {
+		next := make([]*TDigest, (len(level)+1)/2)
+
+		for i := range next {
+			a := level[2*i]
+			var b *TDigest
+			if 2*i+1 < len(level) {
+				b = level[2*i+1]
+			} else if owned {
+				// Odd one out, carry it over to the next round
+				next[i] = a
+				continue
+			}
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, a, b *TDigest) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+
+				dst, err := mergePair(owned, a, b, options)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				next[i] = dst
+			}(i, a, b)
+		}
+
+		wg.Wait()
+		if firstErr != nil {
+			return nil, firstErr
+		}
+
+		level = next
+		owned = true
+	}
+
+	return level[0], nil
+}
+
+// mergePair merges b (which may be nil) into a. Unless a is owned by
+// the caller, a new digest is created to hold the result instead.
+func mergePair(owned bool, a, b *TDigest, options []tdigestOption) (*TDigest, error) {
+	dst := a
+	if !owned {
+		var err error
+		dst, err = New(options...)
+		if err != nil {
+			return nil, err
+		}
+		err = dst.Merge(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if b != nil {
+		err := dst.Merge(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dst, nil
+}
+
+// MixtureModel creates a new digest approximating the mixture of the
+// distributions of the given digests, in which the distribution of
+// digests[i] has weight weights[i]. E.g.: mixing two digests with
+// equal weights yields a digest in which each accounts for half of the
+// samples, regardless of how many samples each one holds.
+//
+// Every centroid is added with its count rescaled so that digests[i]
+// accounts for weights[i] of the total, which is the sum of the counts
+// of all digests. Since counts are integers, each rescaled count is
+// rounded and the rounding error is carried over to the next centroid,
+// so the result holds (about) as many samples as the inputs. The
+// result is created with the given options.
+//
+// This will emit an error if the number of digests and weights differ,
+// if any weight is negative, if the weights don't sum to 1, if a digest
+// with a positive weight is empty or if the options are invalid.
+func MixtureModel(digests []*TDigest, weights []float64, options ...tdigestOption) (*TDigest, error) {
+	if len(digests) != len(weights) {
+		return nil, fmt.Errorf("got %d digests but %d weights", len(digests), len(weights))
+	}
+
+	result, err := New(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		sum   float64
+		total uint64
+	)
+	for i, weight := range weights {
+		if !(weight >= 0) {
+			return nil, fmt.Errorf("weights must be >= 0, got %f at %d", weight, i)
+		}
+		if weight > 0 && digests[i].Count() == 0 {
+			return nil, fmt.Errorf("can't mix the empty digest at %d", i)
+		}
+		sum += weight
+		total += digests[i].Count()
+	}
+
+	if math.Abs(sum-1) > 1e-6 {
+		return nil, fmt.Errorf("weights must sum to 1, got %f", sum)
+	}
+
+	for i, digest := range digests {
+		if weights[i] == 0 {
+			continue
+		}
+
+		scale := weights[i] * float64(total) / float64(digest.Count())
+		// Carries the rounding error over to the next centroid, so
+		// that the total weight of the digest is kept
+		carry := 0.0
+		digest.summary.Perm(result.rng, func(mean float64, count uint64) bool {
+			exact := float64(count)*scale + carry
+			scaled := math.Round(exact)
+			carry = exact - scaled
+			if scaled < 1 {
+				return true
+			}
+			err = result.AddWeighted(mean, uint64(scaled))
+			return err == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,192 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func makeDigests(n, samples int) []*TDigest {
+	rng := rand.New(rand.NewSource(0xCA10))
+
+	digests := make([]*TDigest, n)
+	for i := range digests {
+		digests[i] = uncheckedNew()
+		for j := 0; j < samples; j++ {
+			_ = digests[i].Add(rng.Float64())
+		}
+	}
+	return digests
+}
+
+func TestConcurrentMerge(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{0, 1, 2, 7, 100} {
+		digests := makeDigests(n, 1000)
+
+		var expectedCount uint64
+		sequential := uncheckedNew()
+		for _, d := range digests {
+			expectedCount += d.Count()
+			_ = sequential.Merge(d)
+		}
+
+		for _, workers := range []int{1, 4} {
+			merged, err := ConcurrentMerge(digests, workers)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if merged.Count() != expectedCount {
+				t.Errorf("Expected count %d, got %d (n=%d, workers=%d)",
+					expectedCount, merged.Count(), n, workers)
+			}
+
+			if n == 0 {
+				continue
+			}
+
+			for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.99} {
+				want := sequential.Quantile(q)
+				got := merged.Quantile(q)
+				if math.Abs(got-want) > 0.01 {
+					t.Errorf("Quantile(%.2f): sequential=%.4f concurrent=%.4f (n=%d, workers=%d)",
+						q, want, got, n, workers)
+				}
+			}
+		}
+	}
+}
+
+func TestConcurrentMergePreservesInputs(t *testing.T) {
+	t.Parallel()
+
+	digests := makeDigests(5, 100)
+	counts := make([]uint64, len(digests))
+	for i, d := range digests {
+		counts[i] = d.Count()
+	}
+
+	merged, err := ConcurrentMerge(digests, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, d := range digests {
+		if d.Count() != counts[i] || d == merged {
+			t.Errorf("Input digest %d was modified", i)
+		}
+	}
+}
+
+func TestConcurrentMergeErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := ConcurrentMerge(nil, 0); err == nil {
+		t.Errorf("Expected error for workers=0")
+	}
+
+	if _, err := ConcurrentMerge(nil, 1, Compression(0)); err == nil {
+		t.Errorf("Expected error for invalid options")
+	}
+
+	merged, err := ConcurrentMerge(makeDigests(3, 100), 2, Compression(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Compression() != 10 {
+		t.Errorf("Expected options to be applied to the result")
+	}
+}
+
+func TestMixtureModel(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	low := uncheckedNew()
+	high := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = low.Add(rng.NormFloat64())
+	}
+	// Holds way less samples, but weighs just as much
+	for i := 0; i < 1000; i++ {
+		_ = high.Add(10 + rng.NormFloat64())
+	}
+
+	mixture, err := MixtureModel([]*TDigest{low, high}, []float64{0.5, 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mixture.Count() != 11000 {
+		t.Errorf("Expected the mixture to hold as many samples as the inputs, got %d", mixture.Count())
+	}
+
+	// Bimodal: each mode holds half of the mass, with next to
+	// nothing in between
+	if cdf := mixture.CDF(5); math.Abs(cdf-0.5) > 0.01 {
+		t.Errorf("Expected CDF(5) close to 0.5, got %f", cdf)
+	}
+	if diff := mixture.CDF(7) - mixture.CDF(3); diff > 0.01 {
+		t.Errorf("Expected no mass between the modes, got %f", diff)
+	}
+	if q := mixture.Quantile(0.25); math.Abs(q-low.Quantile(0.5)) > 0.1 {
+		t.Errorf("Expected Quantile(0.25) close to the low median, got %f", q)
+	}
+	if q := mixture.Quantile(0.75); math.Abs(q-high.Quantile(0.5)) > 0.1 {
+		t.Errorf("Expected Quantile(0.75) close to the high median, got %f", q)
+	}
+
+	skewed, err := MixtureModel([]*TDigest{low, high, uncheckedNew()}, []float64{0.9, 0.1, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cdf := skewed.CDF(5); math.Abs(cdf-0.9) > 0.01 {
+		t.Errorf("Expected CDF(5) close to 0.9, got %f", cdf)
+	}
+}
+
+func TestMixtureModelErrors(t *testing.T) {
+	digests := makeDigests(2, 100)
+
+	invalid := []struct {
+		digests []*TDigest
+		weights []float64
+	}{
+		{digests, []float64{1}},
+		{digests, []float64{1.5, -0.5}},
+		{digests, []float64{math.NaN(), 1}},
+		{digests, []float64{0.5, 0.4}},
+		{nil, nil},
+		{[]*TDigest{digests[0], uncheckedNew()}, []float64{0.5, 0.5}},
+	}
+
+	for _, c := range invalid {
+		if _, err := MixtureModel(c.digests, c.weights); err == nil {
+			t.Errorf("Expected error for weights %v", c.weights)
+		}
+	}
+
+	if _, err := MixtureModel(digests, []float64{0.5, 0.5}, Compression(0)); err == nil {
+		t.Errorf("Expected error for invalid options")
+	}
+}
+
+func BenchmarkMergeSequential(b *testing.B) {
+	digests := makeDigests(1000, 1000)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		dst := uncheckedNew()
+		for _, d := range digests {
+			_ = dst.Merge(d)
+		}
+	}
+}
+
+func BenchmarkConcurrentMerge(b *testing.B) {
+	digests := makeDigests(1000, 1000)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = ConcurrentMerge(digests, runtime.NumCPU())
+	}
+}
@@ -0,0 +1,166 @@
+package tdigest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteOpenMetrics writes the digest as a summary metric family in
+// the OpenMetrics (Prometheus) text format.
+//
+// A sample is written for each of the quantiles in qs along with the
+// _sum and _count samples, e.g.:
+//
+//	# HELP latency_seconds Request latency.
+//	# TYPE latency_seconds summary
+//	latency_seconds{quantile="0.99"} 0.489
+//	latency_seconds_sum 5012.7
+//	latency_seconds_count 100000
+//
+// The given labels are added to every sample and are sorted by name
+// so that the output is reproducible. The `# EOF` marker is not
+// written, since the exposition likely contains other metrics.
+//
+// This will emit an error if name is empty, if any of the quantiles
+// is not between 0 and 1 (inclusive), if a label is named "quantile"
+// or if writing to w fails.
+func (t *TDigest) WriteOpenMetrics(w io.Writer, name, help string, labels map[string]string, qs []float64) error {
+	if name == "" {
+		return fmt.Errorf("metric name must not be empty")
+	}
+	for _, q := range qs {
+		if math.IsNaN(q) || q < 0 || q > 1 {
+			return fmt.Errorf("q must be between 0 and 1 (inclusive), got %f", q)
+		}
+	}
+	if _, ok := labels["quantile"]; ok {
+		return fmt.Errorf("the quantile label is reserved")
+	}
+
+	names := make([]string, 0, len(labels))
+	for label := range labels {
+		names = append(names, label)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names)+1)
+	for _, label := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", label, escapeLabelValue(labels[label])))
+	}
+	common := strings.Join(pairs, ",")
+
+	var buf bytes.Buffer
+	if help != "" {
+		fmt.Fprintf(&buf, "# HELP %s %s\n", name, escapeHelp(help))
+	}
+	fmt.Fprintf(&buf, "# TYPE %s summary\n", name)
+
+	for _, q := range qs {
+		quantile := fmt.Sprintf("quantile=\"%s\"", formatFloat(q))
+		if common != "" {
+			quantile = common + "," + quantile
+		}
+		fmt.Fprintf(&buf, "%s{%s} %s\n", name, quantile, formatFloat(t.Quantile(q)))
+	}
+
+	if common != "" {
+		common = "{" + common + "}"
+	}
+	fmt.Fprintf(&buf, "%s_sum%s %s\n", name, common, formatFloat(t.sum()))
+	fmt.Fprintf(&buf, "%s_count%s %d\n", name, common, t.count)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteInfluxLine writes the digest as a single point in the InfluxDB
+// line protocol.
+//
+// A field named after each quantile in qs (in thousandths, so 0.99
+// becomes p990 and 0.999 becomes p999) holds its estimation, and the
+// count field holds the number of samples, e.g.:
+//
+//	latency,host=a p500=0.25,p990=0.49,count=100000i 1700000000000000000
+//
+// The given tags are sorted by name so that the output is reproducible
+// and the timestamp is written in nanoseconds. Since the line protocol
+// can't represent them, quantiles that are not finite (e.g.: all of
+// them, for an empty digest) are omitted.
+//
+// Field names keep up to nine decimal places of the quantile, so
+// quantiles closer than that would share a field.
+//
+// This will emit an error if measurement is empty, if any of the
+// quantiles is not between 0 and 1 (inclusive), if two quantiles map
+// to the same field name, if a tag is empty or if writing to w fails.
+func (t *TDigest) WriteInfluxLine(w io.Writer, measurement string, tags map[string]string, ts time.Time, qs []float64) error {
+	if measurement == "" {
+		return fmt.Errorf("measurement must not be empty")
+	}
+	fields := make([]string, len(qs))
+	seen := make(map[string]bool, len(qs))
+	for i, q := range qs {
+		if math.IsNaN(q) || q < 0 || q > 1 {
+			return fmt.Errorf("q must be between 0 and 1 (inclusive), got %f", q)
+		}
+		fields[i] = "p" + formatFloat(math.Round(q*1e9)/1e6)
+		if seen[fields[i]] {
+			return fmt.Errorf("quantiles must map to distinct field names, got %s twice", fields[i])
+		}
+		seen[fields[i]] = true
+	}
+
+	names := make([]string, 0, len(tags))
+	for tag, value := range tags {
+		if tag == "" || value == "" {
+			return fmt.Errorf("tag keys and values must not be empty")
+		}
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString(measurementEscaper.Replace(measurement))
+	for _, tag := range names {
+		fmt.Fprintf(&buf, ",%s=%s", tagEscaper.Replace(tag), tagEscaper.Replace(tags[tag]))
+	}
+
+	buf.WriteByte(' ')
+	for i, q := range qs {
+		value := t.Quantile(q)
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		fmt.Fprintf(&buf, "%s=%s,", fields[i], formatFloat(value))
+	}
+	fmt.Fprintf(&buf, "count=%di %d\n", t.count, ts.UnixNano())
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+	tagEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+)
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
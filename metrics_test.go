@@ -0,0 +1,258 @@
+package tdigest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	tdigest := uncheckedNew()
+	for i := 1; i <= 100; i++ {
+		_ = tdigest.Add(float64(i))
+	}
+
+	var buf bytes.Buffer
+	labels := map[string]string{"path": "/", "method": "GET"}
+	qs := []float64{0.5, 0.99}
+
+	err := tdigest.WriteOpenMetrics(&buf, "latency_seconds", "Request latency.", labels, qs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wanted := `# HELP latency_seconds Request latency.
+# TYPE latency_seconds summary
+latency_seconds{method="GET",path="/",quantile="0.5"} 50.5
+latency_seconds{method="GET",path="/",quantile="0.99"} 99.01
+latency_seconds_sum{method="GET",path="/"} 5050
+latency_seconds_count{method="GET",path="/"} 100
+`
+	if buf.String() != wanted {
+		t.Fatalf("Unexpected output:\n%s\nwanted:\n%s", buf.String(), wanted)
+	}
+
+	// Values match direct Quantile() calls
+	lines := strings.Split(buf.String(), "\n")
+	for i, q := range qs {
+		fields := strings.Fields(lines[2+i])
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != tdigest.Quantile(q) {
+			t.Errorf("Expected Quantile(%.2f) = %f, got %f", q, tdigest.Quantile(q), value)
+		}
+	}
+}
+
+func TestWriteOpenMetricsCornerCases(t *testing.T) {
+	tdigest := uncheckedNew()
+
+	var buf bytes.Buffer
+	err := tdigest.WriteOpenMetrics(&buf, "empty", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wanted := "# TYPE empty summary\nempty_sum 0\nempty_count 0\n"
+	if buf.String() != wanted {
+		t.Errorf("Unexpected output:\n%s\nwanted:\n%s", buf.String(), wanted)
+	}
+
+	buf.Reset()
+	err = tdigest.WriteOpenMetrics(&buf, "escaped", "a \\ b\nc", map[string]string{"l": "\"x\"\n"}, []float64{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wanted = `# HELP escaped a \\ b\nc
+# TYPE escaped summary
+escaped{l="\"x\"\n",quantile="1"} NaN
+escaped_sum{l="\"x\"\n"} 0
+escaped_count{l="\"x\"\n"} 0
+`
+	if buf.String() != wanted {
+		t.Errorf("Unexpected output:\n%s\nwanted:\n%s", buf.String(), wanted)
+	}
+
+	if tdigest.WriteOpenMetrics(&buf, "", "", nil, nil) == nil {
+		t.Errorf("Expected an error with an empty metric name")
+	}
+
+	if tdigest.WriteOpenMetrics(&buf, "m", "", nil, []float64{math.NaN()}) == nil {
+		t.Errorf("Expected an error with an invalid quantile")
+	}
+
+	if tdigest.WriteOpenMetrics(&buf, "m", "", map[string]string{"quantile": "x"}, nil) == nil {
+		t.Errorf("Expected an error with a quantile label")
+	}
+
+	if tdigest.WriteOpenMetrics(failingWriter{}, "m", "", nil, nil) == nil {
+		t.Errorf("Expected write errors to be reported")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+// parseInfluxLine is a minimal line protocol parser: it doesn't
+// handle escaping nor string fields
+func parseInfluxLine(t *testing.T, line string) (string, map[string]string, map[string]string, int64) {
+	parts := strings.Split(strings.TrimSuffix(line, "\n"), " ")
+	if len(parts) != 3 {
+		t.Fatalf("Expected 3 sections in line %q, got %d", line, len(parts))
+	}
+
+	series := strings.Split(parts[0], ",")
+	tags := make(map[string]string)
+	for _, tag := range series[1:] {
+		kv := strings.SplitN(tag, "=", 2)
+		tags[kv[0]] = kv[1]
+	}
+
+	fields := make(map[string]string)
+	for _, field := range strings.Split(parts[1], ",") {
+		kv := strings.SplitN(field, "=", 2)
+		fields[kv[0]] = kv[1]
+	}
+
+	ts, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return series[0], tags, fields, ts
+}
+
+func TestWriteInfluxLine(t *testing.T) {
+	tdigest := uncheckedNew()
+	for i := 1; i <= 100; i++ {
+		_ = tdigest.Add(float64(i))
+	}
+
+	var buf bytes.Buffer
+	tags := map[string]string{"region": "eu", "host": "a"}
+	qs := []float64{0.5, 0.99, 0.999}
+	ts := time.Unix(1700000000, 42)
+
+	err := tdigest.WriteInfluxLine(&buf, "latency", tags, ts, qs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wanted := "latency,host=a,region=eu p500=50.5,p990=99.01,p999=99.901,count=100i 1700000000000000042\n"
+	if buf.String() != wanted {
+		t.Fatalf("Unexpected output:\n%s\nwanted:\n%s", buf.String(), wanted)
+	}
+
+	measurement, gotTags, fields, gotTs := parseInfluxLine(t, buf.String())
+	if measurement != "latency" || len(gotTags) != 2 || gotTags["host"] != "a" || gotTs != ts.UnixNano() {
+		t.Errorf("Unexpected series: %s %v %d", measurement, gotTags, gotTs)
+	}
+
+	for _, field := range []string{"p500", "p990", "p999"} {
+		q, _ := strconv.ParseFloat(field[1:], 64)
+		value, err := strconv.ParseFloat(fields[field], 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != tdigest.Quantile(q/1000) {
+			t.Errorf("Field %s=%f doesn't match Quantile(%.3f)", field, value, q/1000)
+		}
+	}
+
+	if fields["count"] != "100i" {
+		t.Errorf("Expected count=100i, got %s", fields["count"])
+	}
+}
+
+func TestWriteInfluxLineCornerCases(t *testing.T) {
+	tdigest := uncheckedNew()
+	ts := time.Unix(0, 1)
+
+	var buf bytes.Buffer
+	err := tdigest.WriteInfluxLine(&buf, "my latency", map[string]string{"a,b": "c=d"}, ts, []float64{0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Empty digests only report the count; Special chars are escaped
+	if buf.String() != "my\\ latency,a\\,b=c\\=d count=0i 1\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+
+	if tdigest.WriteInfluxLine(&buf, "", nil, ts, nil) == nil {
+		t.Errorf("Expected error for empty measurement")
+	}
+
+	if tdigest.WriteInfluxLine(&buf, "m", map[string]string{"a": ""}, ts, nil) == nil {
+		t.Errorf("Expected error for empty tag value")
+	}
+
+	for _, q := range []float64{-0.1, 1.1, math.NaN()} {
+		if tdigest.WriteInfluxLine(&buf, "m", nil, ts, []float64{q}) == nil {
+			t.Errorf("Expected error for q=%f", q)
+		}
+	}
+
+	// Duplicated field names
+	for _, qs := range [][]float64{{0.5, 0.5}, {0.99, 0.9900000000001}} {
+		if tdigest.WriteInfluxLine(&buf, "m", nil, ts, qs) == nil {
+			t.Errorf("Expected error for colliding quantiles %v", qs)
+		}
+	}
+
+	if tdigest.WriteInfluxLine(failingWriter{}, "m", nil, ts, nil) == nil {
+		t.Errorf("Expected writer errors to be propagated")
+	}
+}
+
+func TestWriteInfluxLineHTTP(t *testing.T) {
+	tdigest := uncheckedNew()
+	for i := 1; i <= 100; i++ {
+		_ = tdigest.Add(float64(i))
+	}
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/write" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	err := tdigest.WriteInfluxLine(&buf, "latency", nil, time.Unix(1, 0), []float64{0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Post(server.URL+"/write?db=test&precision=ns", "text/plain", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", resp.StatusCode)
+	}
+
+	if received != "latency p500=50.5,count=100i 1000000000\n" {
+		t.Errorf("Unexpected payload: %q", received)
+	}
+}
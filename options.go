@@ -1,9 +1,17 @@
 package tdigest
 
-import "errors"
+import (
+	"errors"
+	"math"
+)
 
 type tdigestOption func(*TDigest) error
 
+// Option configures a digest, see New. It's only needed for passing
+// options along, e.g.: from a function that builds digests on behalf
+// of its callers.
+type Option = tdigestOption
+
 // Compression sets the digest compression
 //
 // The compression parameter rules the threshold in which samples are
@@ -27,6 +35,155 @@ func Compression(compression float64) tdigestOption { // nolint
 	}
 }
 
+// How much the compression grows for every tenfold increase in the
+// number of samples when using AdaptiveCompression.
+const adaptiveCompressionScale = 20
+
+// AdaptiveCompression makes the digest compression grow along with
+// the number of samples it holds
+//
+// The digest starts with compression set to min and, as the count
+// grows, the compression is raised to log10(Count()) * 20, never
+// exceeding max. E.g.: a digest with 100000 samples will use a
+// compression of 100 if allowed by the bounds. This avoids holding
+// needlessly many centroids for sparse early data while keeping
+// appropriate accuracy for mature digests. The digest is compressed
+// every time the compression is raised, which happens at most once
+// for every tenfold increase in the number of samples.
+//
+// Both min and max must be greater or equal to 1 and max must not be
+// lower than min, will yield an error otherwise.
+func AdaptiveCompression(min, max float64) tdigestOption { // nolint
+	return func(t *TDigest) error {
+		if min < 1 {
+			return errors.New("Compression should be >= 1")
+		}
+		if max < min {
+			return errors.New("max compression should be >= min compression")
+		}
+		t.compression = min
+		t.minCompression = min
+		t.maxCompression = max
+		return nil
+	}
+}
+
+// MaxCentroids sets a hard limit on the number of centroids
+//
+// Regardless of the compression, whenever an addition makes the digest
+// hold more than n centroids it's compressed (at most once every time
+// the count doubles) and the adjacent centroids with the closest means
+// are merged until the limit is respected. This trades accuracy
+// (notably on the tails) for a strict bound on memory usage. The
+// limit is only effective if it's lower than what the compression
+// allows, so it's mostly useful for memory-constrained environments.
+// See also NewFixedSizeDigest.
+//
+// The limit must be greater or equal to 1, will yield an error
+// otherwise.
+func MaxCentroids(n int) tdigestOption { // nolint
+	return func(t *TDigest) error {
+		if n < 1 {
+			return errors.New("MaxCentroids should be >= 1")
+		}
+		t.maxCentroids = n
+		return nil
+	}
+}
+
+// CompressAfter sets the number of centroids that triggers a full
+// compression of the digest, which is 20 times the compression by
+// default.
+//
+// Lower thresholds keep the memory footprint of the digest in check
+// at the cost of more frequent compressions, higher ones make adding
+// samples cheaper. Since compressing yields roughly as many centroids
+// as the compression allows, thresholds below that make every new
+// centroid trigger a (useless) compression: use MaxCentroids for a
+// hard limit on the number of centroids instead.
+//
+// The threshold must be >= 1, will yield an error otherwise.
+func CompressAfter(n int) tdigestOption { // nolint
+	return func(t *TDigest) error {
+		if n <= 0 {
+			return errors.New("CompressAfter should be >= 1")
+		}
+		t.compressThreshold = n
+		return nil
+	}
+}
+
+// LazyCompress defers compressing the digest until it's queried
+//
+// By default, an addition that makes the digest hold too many
+// centroids (see CompressAfter) compresses it right away, which makes
+// that particular call much slower than the others. With this option
+// the compression is only recorded as pending and carried out by the
+// next call to Quantile, Quantiles, CDF, CDFs or Compress, moving the
+// cost away from latency-sensitive paths that only add samples.
+//
+// Meanwhile the digest keeps growing (and additions get slower as it
+// does), so it's meant for digests that are queried or compressed
+// regularly. Other queries work normally on a digest with a pending
+// compression, as do serialization and merging. The MaxCentroids
+// limit is still enforced right away.
+func LazyCompress() tdigestOption { // nolint
+	return func(t *TDigest) error {
+		t.lazyCompress = true
+		return nil
+	}
+}
+
+// ScaleFunction sets how big centroids may grow depending on where
+// they sit in the distribution
+//
+// Given a quantile q and the digest compression, fn must return the
+// maximum size of a centroid at q as a fraction of the total count:
+// only centroids whose size would remain within that limit are
+// considered when merging new samples. Limits that shrink towards the
+// tails (q near 0 or 1) keep extreme quantiles accurate, uniform ones
+// favor the median. See ScaleFunctionK0, ScaleFunctionK1 and
+// ScaleFunctionK2 (the default) for the functions from the t-digest
+// paper.
+//
+// The function is not serialized: digests restored from bytes or JSON
+// use the default unless configured otherwise. A nil fn yields an
+// error.
+func ScaleFunction(fn func(q, compression float64) float64) tdigestOption { // nolint
+	return func(t *TDigest) error {
+		if fn == nil {
+			return errors.New("scale function must not be nil")
+		}
+		t.scaleFunc = fn
+		return nil
+	}
+}
+
+// ScaleFunctionK0 limits every centroid to 2/compression of the
+// samples, regardless of q. This gives uniform accuracy across the
+// distribution, but poor accuracy on the tails.
+func ScaleFunctionK0() tdigestOption { // nolint
+	return ScaleFunction(func(q, compression float64) float64 {
+		return 2 / compression
+	})
+}
+
+// ScaleFunctionK1 limits centroids to pi*sqrt(q*(1-q))/compression of
+// the samples, which shrinks towards the tails more gently than K2.
+func ScaleFunctionK1() tdigestOption { // nolint
+	return ScaleFunction(func(q, compression float64) float64 {
+		return math.Pi * math.Sqrt(q*(1-q)) / compression
+	})
+}
+
+// ScaleFunctionK2 limits centroids to 4*q*(1-q)/compression of the
+// samples, favoring accuracy on the tails. This is the default.
+func ScaleFunctionK2() tdigestOption { // nolint
+	return ScaleFunction(func(q, compression float64) float64 {
+		return 4 * q * (1 - q) / compression
+	})
+}
+
 // RandomNumberGenerator sets the RNG to be used internally
 //
 // This allows changing which random number source is used when using
@@ -43,9 +200,44 @@ func RandomNumberGenerator(rng RNG) tdigestOption { // nolint
 	}
 }
 
+// CustomRNG makes the TDigest use the given random source, e.g.: a
+// PCG or xoshiro implementation, or a *rand.Rand (which implements
+// TDigestRNG as is).
+//
+// Like with RandomNumberGenerator, the source is shared by clones and
+// must be safe for concurrent use if the digest is used concurrently
+// (e.g.: via ConcurrentMerge). Unlike it, a nil rng yields an error
+// instead of silently falling back to the default.
+func CustomRNG(rng TDigestRNG) tdigestOption { // nolint
+	return func(t *TDigest) error {
+		if rng == nil {
+			return errors.New("rng must not be nil")
+		}
+		t.rng = rng
+		return nil
+	}
+}
+
 // LocalRandomNumberGenerator makes the TDigest use the default
 // `math/random` functions but with an unshared source that is
 // seeded with the given `seed` parameter.
+//
+// This is the default, with a seed of 1. Since the source isn't
+// shared, digests configured with the same seed that receive the same
+// samples end up identical, which makes tests and benchmarks
+// reproducible. Clones get their own source, seeded the same way.
 func LocalRandomNumberGenerator(seed int64) tdigestOption { // nolint
 	return RandomNumberGenerator(newLocalRNG(seed))
 }
+
+// GlobalRandomNumberGenerator makes the TDigest use the top-level
+// `math/rand` functions, which share a single source.
+//
+// The shared source is safe for concurrent use, but that comes at the
+// cost of lock contention, so this is mostly useful for keeping the
+// behaviour of older versions of this package, in which it was the
+// default. Results are not reproducible, unless the global source is
+// seeded via rand.Seed.
+func GlobalRandomNumberGenerator() tdigestOption { // nolint
+	return RandomNumberGenerator(globalRNG{})
+}
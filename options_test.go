@@ -1,6 +1,15 @@
 package tdigest
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
 
 func TestDefaults(t *testing.T) {
 	digest, err := New()
@@ -9,14 +18,14 @@ func TestDefaults(t *testing.T) {
 		t.Errorf("Creating a default TDigest should never error out. Got %s", err)
 	}
 
-	if digest.compression != 100 {
+	if digest.Compression() != 100 {
 		t.Errorf("The default compression should be 100")
 	}
 }
 
 func TestCompression(t *testing.T) {
 	digest, _ := New(Compression(40))
-	if digest.compression != 40 {
+	if digest.Compression() != 40 {
 		t.Errorf("The compression option should change the new digest compression")
 	}
 
@@ -43,3 +52,464 @@ func TestRandomNumberGenerator(t *testing.T) {
 		}
 	}
 }
+
+func TestGlobalRandomNumberGenerator(t *testing.T) {
+	digest, err := New(GlobalRandomNumberGenerator())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := digest.rng.(globalRNG); !ok {
+		t.Errorf("Expected the global RNG to be configured, got %T", digest.rng)
+	}
+
+	if _, ok := digest.Clone().rng.(globalRNG); !ok {
+		t.Errorf("Expected clones to keep using the global RNG")
+	}
+
+	if _, ok := uncheckedNew().rng.(*localRNG); !ok {
+		t.Errorf("Expected a local RNG by default")
+	}
+}
+
+// Counts how often the digest draws from the wrapped source
+type countingRNG struct {
+	r     *rand.Rand
+	calls int
+}
+
+func (c *countingRNG) Float32() float32 {
+	c.calls++
+	return c.r.Float32()
+}
+
+func (c *countingRNG) Intn(n int) int {
+	c.calls++
+	return c.r.Intn(n)
+}
+
+func TestCustomRNG(t *testing.T) {
+	digests := make([]*TDigest, 2)
+	sources := make([]*countingRNG, 2)
+	for i := range digests {
+		sources[i] = &countingRNG{r: rand.New(rand.NewSource(0xCA10))}
+		digest, err := New(CustomRNG(sources[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		digests[i] = digest
+	}
+
+	data := rand.New(rand.NewSource(42))
+	for i := 0; i < 10000; i++ {
+		value := data.Float64()
+		_ = digests[0].Add(value)
+		_ = digests[1].Add(value)
+	}
+
+	if sources[0].calls == 0 {
+		t.Errorf("Expected the custom RNG to be used")
+	}
+
+	if digests[0].Checksum() != digests[1].Checksum() {
+		t.Errorf("Expected identically seeded sources to yield identical digests")
+	}
+
+	// *rand.Rand implements the interface as is
+	if _, err := New(CustomRNG(rand.New(rand.NewSource(1)))); err != nil {
+		t.Errorf("Expected *rand.Rand to be accepted, got %v", err)
+	}
+
+	if _, err := New(CustomRNG(nil)); err == nil {
+		t.Errorf("Expected error for a nil RNG")
+	}
+}
+
+func TestAdaptiveCompression(t *testing.T) {
+	for _, bounds := range [][2]float64{{0, 10}, {10, 5}} {
+		digest, err := New(AdaptiveCompression(bounds[0], bounds[1]))
+		if err == nil || digest != nil {
+			t.Errorf("Trying to create a digest with bad adaptive bounds %v should give an error", bounds)
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(0xCA10))
+	adaptive, _ := New(AdaptiveCompression(10, 100))
+	fixed, _ := New(Compression(10))
+
+	if adaptive.Compression() != 10 {
+		t.Errorf("The adaptive compression should start at min. Got %.2f", adaptive.Compression())
+	}
+
+	data := make([]float64, 0, 100000)
+	lastCompression := adaptive.Compression()
+	for _, checkpoint := range []int{10, 100, 1000, 10000, 100000} {
+		for len(data) < checkpoint {
+			x := rnd.Float64()
+			data = append(data, x)
+			_ = adaptive.Add(x)
+			_ = fixed.Add(x)
+		}
+
+		compression := adaptive.Compression()
+		if compression < 10 || compression > 100 {
+			t.Errorf("Compression %.2f out of bounds at count=%d", compression, adaptive.Count())
+		}
+		if compression < lastCompression {
+			t.Errorf("Compression should never decrease. Got %.2f < %.2f", compression, lastCompression)
+		}
+		lastCompression = compression
+	}
+
+	if lastCompression != 100 {
+		t.Errorf("Expected compression to reach max. Got %.2f", lastCompression)
+	}
+
+	// A grown compression gives better accuracy than staying at min
+	sort.Float64s(data)
+	var adaptiveError, fixedError float64
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		z := data[int(q*float64(len(data)))]
+		adaptiveError += math.Abs(adaptive.Quantile(q) - z)
+		fixedError += math.Abs(fixed.Quantile(q) - z)
+	}
+	if adaptiveError >= fixedError {
+		t.Errorf("Expected adaptive compression to be more accurate. Got %.6f >= %.6f", adaptiveError, fixedError)
+	}
+}
+
+func TestMaxCentroids(t *testing.T) {
+	digest, err := New(MaxCentroids(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	data := make([]float64, 1000000)
+	for i := range data {
+		data[i] = rng.NormFloat64()
+		_ = digest.Add(data[i])
+		if digest.Len() > 200 {
+			t.Fatalf("Expected at most 200 centroids, got %d after %d samples", digest.Len(), i+1)
+		}
+	}
+
+	if digest.Count() != uint64(len(data)) {
+		t.Errorf("Expected count %d, got %d", len(data), digest.Count())
+	}
+
+	sort.Float64s(data)
+	for _, q := range []float64{0.01, 0.5, 0.99} {
+		if math.Abs(digest.Quantile(q)-data[int(q*float64(len(data)))]) > 0.05 {
+			t.Errorf("Quantile(%.2f) too far from the data: %f", q, digest.Quantile(q))
+		}
+	}
+
+	// A limit lower than what the compression allows
+	tiny, _ := New(MaxCentroids(10))
+	for _, x := range data[:10000] {
+		_ = tiny.Add(x)
+	}
+	if tiny.Len() > 10 || tiny.Count() != 10000 {
+		t.Errorf("Expected at most 10 centroids and 10000 samples, got %d and %d", tiny.Len(), tiny.Count())
+	}
+
+	if tiny.Clone().maxCentroids != 10 {
+		t.Errorf("Expected the limit to be cloned")
+	}
+
+	if _, err := New(MaxCentroids(0)); err == nil {
+		t.Errorf("Expected error for MaxCentroids(0)")
+	}
+}
+
+func TestCompressAfter(t *testing.T) {
+	maxLen := func(digest *TDigest) int {
+		// Increasing values always create new centroids
+		max := 0
+		for i := 0; i < 10000; i++ {
+			_ = digest.Add(float64(i))
+			if digest.Len() > max {
+				max = digest.Len()
+			}
+		}
+		return max
+	}
+
+	// Compresses after 20*10 = 200 centroids by default
+	digest, _ := New(Compression(10))
+	if max := maxLen(digest); max != 200 {
+		t.Errorf("Expected the default threshold to allow 200 centroids, got %d", max)
+	}
+
+	digest, err := New(Compression(10), CompressAfter(150))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if max := maxLen(digest); max != 150 {
+		t.Errorf("Expected the custom threshold to allow 150 centroids, got %d", max)
+	}
+
+	if digest.Count() != 10000 || digest.Validate() != nil {
+		t.Errorf("Expected a valid digest with 10000 samples")
+	}
+
+	if digest.Clone().compressThreshold != 150 {
+		t.Errorf("Expected the threshold to be cloned")
+	}
+
+	// Below what the compression yields: compresses on every new
+	// centroid, but must still work
+	digest, _ = New(CompressAfter(1))
+	if maxLen(digest); digest.Count() != 10000 || digest.Validate() != nil {
+		t.Errorf("Expected a tiny threshold to keep the digest valid")
+	}
+
+	if _, err := New(CompressAfter(0)); err == nil {
+		t.Errorf("Expected error for CompressAfter(0)")
+	}
+}
+
+func TestLazyCompress(t *testing.T) {
+	digest, err := New(Compression(10), LazyCompress())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Increasing values always create new centroids, way past the
+	// 20*10 = 200 that trigger a compression
+	for i := 0; i < 1000; i++ {
+		_ = digest.Add(float64(i))
+	}
+	if digest.Len() != 1000 || !digest.needsCompress {
+		t.Fatalf("Expected 1000 centroids pending compression, got %d", digest.Len())
+	}
+
+	// Other queries don't compress
+	if digest.Mean() != 499.5 || digest.Len() != 1000 {
+		t.Errorf("Expected Mean() to leave the centroids alone, got %d", digest.Len())
+	}
+
+	clone := digest.Clone()
+	if !clone.lazyCompress || !clone.needsCompress {
+		t.Errorf("Expected the clone to keep the pending compression")
+	}
+
+	median := digest.Quantile(0.5)
+	if digest.Len() >= 200 || digest.needsCompress {
+		t.Errorf("Expected Quantile to compress the digest, got %d centroids", digest.Len())
+	}
+	if math.Abs(median-499.5) > 10 || digest.Count() != 1000 || digest.Validate() != nil {
+		t.Errorf("Expected a valid digest with 1000 samples and median ~499.5, got %f", median)
+	}
+
+	if clone.CDF(500); clone.Len() >= 200 {
+		t.Errorf("Expected CDF to compress the digest, got %d centroids", clone.Len())
+	}
+
+	for i := 0; i < 1000; i++ {
+		_ = digest.Add(float64(i))
+	}
+	if err := digest.Compress(); err != nil || digest.needsCompress {
+		t.Errorf("Expected Compress to clear the pending compression")
+	}
+
+	// Freezing compresses too, so frozen digests can still be queried
+	for i := 0; i < 1000; i++ {
+		_ = clone.Add(float64(i))
+	}
+	clone.Freeze()
+	if clone.needsCompress || math.IsNaN(clone.Quantile(0.5)) {
+		t.Errorf("Expected Freeze to carry out the pending compression")
+	}
+}
+
+// Used to corrupt the digest: MarshalJSON compressed a copy sharing
+// the centroid arrays of the original
+func TestLazyCompressMarshalJSON(t *testing.T) {
+	build := func() *TDigest {
+		digest, _ := New(Compression(10), LazyCompress())
+		for i := 0; i < 1000; i++ {
+			_ = digest.Add(float64(i))
+		}
+		if !digest.needsCompress {
+			t.Fatalf("Expected a compression to be pending")
+		}
+		return digest
+	}
+
+	for name, marshal := range map[string]func(*TDigest) ([]byte, error){
+		"pointer": func(d *TDigest) ([]byte, error) { return json.Marshal(d) },
+		"value":   func(d *TDigest) ([]byte, error) { return json.Marshal(*d) },
+		"field": func(d *TDigest) ([]byte, error) {
+			return json.Marshal(struct{ Digest TDigest }{*d})
+		},
+	} {
+		digest := build()
+		if _, err := marshal(digest); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := digest.Validate(); err != nil {
+			t.Errorf("%s: invalid digest after marshaling: %v", name, err)
+		}
+		if digest.Min() != 0 || digest.Max() != 999 || digest.Count() != 1000 {
+			t.Errorf("%s: expected min 0, max 999 and 1000 samples, got %f, %f and %d",
+				name, digest.Min(), digest.Max(), digest.Count())
+		}
+
+		data, _ := digest.AsBytes()
+		restored, err := FromBytes(bytes.NewReader(data))
+		if err != nil || restored.Validate() != nil {
+			t.Errorf("%s: expected a valid binary round-trip, got %v", name, err)
+		}
+	}
+}
+
+func TestScaleFunction(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = math.Exp(rng.NormFloat64())
+	}
+
+	build := func(options ...tdigestOption) *TDigest {
+		digest, err := New(options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, x := range data {
+			_ = digest.Add(x)
+		}
+		return digest
+	}
+
+	if build(ScaleFunctionK2()).Checksum() != build().Checksum() {
+		t.Errorf("Expected ScaleFunctionK2 to behave like the default")
+	}
+
+	calls := 0
+	custom := build(ScaleFunction(func(q, compression float64) float64 {
+		calls++
+		return 1 / compression
+	}))
+	if calls == 0 {
+		t.Errorf("Expected the custom scale function to be used")
+	}
+	if custom.Clone().scaleFunc == nil {
+		t.Errorf("Expected the scale function to be cloned")
+	}
+
+	sorted := append([]float64{}, data...)
+	sort.Float64s(sorted)
+	p99 := sorted[int(0.99*float64(len(sorted)))]
+
+	k0 := math.Abs(build(ScaleFunctionK0()).Quantile(0.99) - p99)
+	k2 := math.Abs(build(ScaleFunctionK2()).Quantile(0.99) - p99)
+	if k2 >= k0 {
+		t.Errorf("Expected K2 to be more accurate than K0 at P99. Got %f >= %f", k2, k0)
+	}
+
+	for _, digest := range []*TDigest{custom, build(ScaleFunctionK0()), build(ScaleFunctionK1())} {
+		if digest.Count() != uint64(len(data)) || digest.Validate() != nil {
+			t.Errorf("Expected a valid digest with %d samples", len(data))
+		}
+	}
+
+	if _, err := New(ScaleFunction(nil)); err == nil {
+		t.Errorf("Expected error for a nil scale function")
+	}
+}
+
+func BenchmarkCompressAfter(b *testing.B) {
+	data := make([]float64, 100000)
+	rng := rand.New(rand.NewSource(0xCA10))
+	for i := range data {
+		data[i] = rng.Float64()
+	}
+
+	for _, threshold := range []int{1000, 2000, 5000, 20000} {
+		b.Run(fmt.Sprint(threshold), func(b *testing.B) {
+			b.ReportAllocs()
+			digest, _ := New(CompressAfter(threshold))
+			for n := 0; n < b.N; n++ {
+				_ = digest.Add(data[n%len(data)])
+			}
+		})
+	}
+}
+
+// Reports the relative error of the P99 estimation of a log-normal
+// distribution for each scale function
+func BenchmarkScaleFunctionP99(b *testing.B) {
+	data := make([]float64, 100000)
+	rng := rand.New(rand.NewSource(0xCA10))
+	for i := range data {
+		data[i] = math.Exp(rng.NormFloat64())
+	}
+	sorted := append([]float64{}, data...)
+	sort.Float64s(sorted)
+	p99 := sorted[int(0.99*float64(len(sorted)))]
+
+	scales := []struct {
+		name   string
+		option tdigestOption
+	}{
+		{"K0", ScaleFunctionK0()},
+		{"K1", ScaleFunctionK1()},
+		{"K2", ScaleFunctionK2()},
+	}
+
+	for _, scale := range scales {
+		b.Run(scale.name, func(b *testing.B) {
+			var relativeError float64
+			for n := 0; n < b.N; n++ {
+				digest, _ := New(scale.option)
+				for _, x := range data {
+					_ = digest.Add(x)
+				}
+				relativeError = math.Abs(digest.Quantile(0.99)-p99) / p99
+			}
+			b.ReportMetric(relativeError, "p99-error")
+		})
+	}
+}
+
+// Reports the tail latency of Add besides the throughput: compressing
+// right away makes a few calls orders of magnitude slower than the rest
+func BenchmarkLazyCompress(b *testing.B) {
+	data := make([]float64, 100000)
+	rng := rand.New(rand.NewSource(0xCA10))
+	for i := range data {
+		data[i] = rng.Float64()
+	}
+
+	for _, lazy := range []bool{false, true} {
+		b.Run(fmt.Sprintf("lazy=%t", lazy), func(b *testing.B) {
+			options := []tdigestOption{}
+			if lazy {
+				options = append(options, LazyCompress())
+			}
+			digest, _ := New(options...)
+
+			latencies := make([]time.Duration, b.N)
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				start := time.Now()
+				_ = digest.Add(data[n%len(data)])
+				latencies[n] = time.Since(start)
+
+				// Queried every 10000 samples, outside of the Add path
+				if lazy && n%10000 == 9999 {
+					b.StopTimer()
+					_ = digest.Compress()
+					b.StartTimer()
+				}
+			}
+			b.StopTimer()
+
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			b.ReportMetric(float64(latencies[len(latencies)*999/1000].Nanoseconds()), "p999-ns")
+		})
+	}
+}
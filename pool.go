@@ -0,0 +1,83 @@
+package tdigest
+
+import "sync"
+
+// Pool is a set of reusable digests.
+//
+// It's a thin typed wrapper around sync.Pool that lets hot paths (a
+// digest per HTTP request, say) skip the allocation of the internal
+// data structures. A Pool is safe for concurrent use, but the digests
+// it hands out are not.
+type Pool struct {
+	pool sync.Pool
+	// Holds the configuration every pooled digest goes back to
+	base *TDigest
+}
+
+// DigestPool is an alias of Pool.
+type DigestPool = Pool
+
+// NewPool creates a pool of digests configured with the given options.
+//
+// The options are applied to every digest created by the pool, so
+// passing a RNG via RandomNumberGenerator or LocalRandomNumberGenerator
+// makes all the digests share the same (not thread-safe) instance.
+//
+// NewPool panics if the options are invalid.
+func NewPool(options ...tdigestOption) *Pool {
+	first, err := New(options...)
+	if err != nil {
+		panic(err)
+	}
+
+	p := &Pool{base: first.Clone()}
+	p.pool.New = func() interface{} {
+		t, _ := New(options...)
+		return t
+	}
+	p.pool.Put(first)
+	return p
+}
+
+// Get returns an empty digest from the pool, creating a new one if
+// necessary.
+//
+// The given options, if any, are applied on top of the configuration
+// of the pool and last until the digest is returned via Put. Get
+// panics if the options are invalid.
+func (p *Pool) Get(options ...tdigestOption) *TDigest {
+	t := p.pool.Get().(*TDigest)
+	for _, option := range options {
+		err := option(t)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return t
+}
+
+// Put empties the given digest, restores the configuration of the
+// pool and returns it to the pool. The digest must not be used after
+// calling Put.
+func (p *Pool) Put(t *TDigest) {
+	t.compression = p.base.compression
+	t.minCompression = p.base.minCompression
+	t.maxCompression = p.base.maxCompression
+	t.maxCentroids = p.base.maxCentroids
+	t.compressThreshold = p.base.compressThreshold
+	t.scaleFunc = p.base.scaleFunc
+	t.lazyCompress = p.base.lazyCompress
+
+	// Local sources are costly to allocate, so they are kept unless
+	// Get replaced them
+	if base, ok := p.base.rng.(*localRNG); ok {
+		if current, ok := t.rng.(*localRNG); !ok || current.seed != base.seed {
+			t.rng = newLocalRNG(base.seed)
+		}
+	} else {
+		t.rng = p.base.rng
+	}
+
+	t.Reset()
+	p.pool.Put(t)
+}
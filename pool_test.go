@@ -0,0 +1,101 @@
+package tdigest
+
+import "testing"
+
+func TestPool(t *testing.T) {
+	pool := NewPool(Compression(42))
+
+	digest := pool.Get()
+	if digest.Count() != 0 || digest.Compression() != 42 {
+		t.Fatalf("Expected an empty digest with compression 42")
+	}
+
+	for i := 0; i < 1000; i++ {
+		_ = digest.Add(float64(i))
+	}
+	if digest.Count() != 1000 {
+		t.Errorf("Expected a pooled digest to be fully functional")
+	}
+
+	pool.Put(digest)
+
+	// The pool may or may not give back the same digest,
+	// but it must be empty either way
+	for i := 0; i < 2; i++ {
+		digest = pool.Get()
+		if digest.Count() != 0 || digest.summary.Len() != 0 {
+			t.Errorf("Expected an empty digest, got count=%d", digest.Count())
+		}
+		if digest.Compression() != 42 {
+			t.Errorf("Expected compression 42, got %.2f", digest.Compression())
+		}
+
+		_ = digest.Add(1)
+		if digest.Quantile(0.5) != 1 {
+			t.Errorf("Expected a pooled digest to be fully functional")
+		}
+	}
+
+	shouldPanic(func() {
+		NewPool(Compression(0))
+	}, t, "NewPool() with invalid options should panic!")
+}
+
+func TestPoolGetWithOptions(t *testing.T) {
+	var pool *DigestPool = NewPool(Compression(42))
+
+	digest := pool.Get(Compression(10), MaxCentroids(5), CustomRNG(globalRNG{}))
+	if digest.Compression() != 10 || digest.maxCentroids != 5 {
+		t.Errorf("Expected the options given to Get to be applied")
+	}
+
+	for i := 0; i < 1000; i++ {
+		_ = digest.Add(float64(i))
+	}
+	if digest.Len() > 5 {
+		t.Errorf("Expected at most 5 centroids, got %d", digest.Len())
+	}
+
+	pool.Put(digest)
+
+	// Whether or not it's the same digest, the options given to Get
+	// must not leak into the next user
+	for i := 0; i < 2; i++ {
+		digest = pool.Get()
+		if digest.Compression() != 42 || digest.maxCentroids != 0 {
+			t.Errorf("Expected the configuration of the pool, got compression=%.2f maxCentroids=%d", digest.Compression(), digest.maxCentroids)
+		}
+		if _, ok := digest.rng.(*localRNG); !ok {
+			t.Errorf("Expected the RNG of the pool, got %T", digest.rng)
+		}
+		pool.Put(digest)
+	}
+
+	shouldPanic(func() {
+		pool.Get(Compression(0))
+	}, t, "Get() with invalid options should panic!")
+}
+
+func BenchmarkPoolReuse(b *testing.B) {
+	b.ReportAllocs()
+
+	pool := NewPool()
+	for n := 0; n < b.N; n++ {
+		digest := pool.Get()
+		for i := 0; i < 100; i++ {
+			_ = digest.Add(float64(i))
+		}
+		pool.Put(digest)
+	}
+}
+
+func BenchmarkNewPerUse(b *testing.B) {
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		digest, _ := New()
+		for i := 0; i < 100; i++ {
+			_ = digest.Add(float64(i))
+		}
+	}
+}
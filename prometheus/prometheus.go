@@ -0,0 +1,143 @@
+// Package prometheus converts between t-digests and the Prometheus
+// data model (github.com/prometheus/client_model).
+//
+// It lives in its own package so that users of tdigest that don't
+// need it aren't forced to depend on the Prometheus libraries.
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/caio/go-tdigest/v4"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// ToPrometheusHistogram exports the digest as a Prometheus histogram
+// with the given bucket upper bounds.
+//
+// The (cumulative) count of each bucket is estimated as
+// CDF(bound) * Count(), rounded to the nearest integer. The sample
+// sum is computed from the centroids (the sum of mean * count), so it
+// is exact unless centroids have been merged with values of very
+// different magnitudes. Like in Prometheus, the implicit +Inf bucket
+// is represented by the sample count, so it doesn't need to be given.
+//
+// The buckets must be sorted in ascending order and must not contain
+// NaN, will panic otherwise.
+func ToPrometheusHistogram(t *tdigest.TDigest, buckets []float64) *dto.Histogram {
+	for _, bound := range buckets {
+		if math.IsNaN(bound) {
+			panic("buckets must not contain NaN")
+		}
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic("buckets must be sorted in ascending order")
+	}
+
+	count := t.Count()
+	sum := 0.0
+	t.ForEachCentroid(func(mean float64, count uint64) bool {
+		sum += mean * float64(count)
+		return true
+	})
+
+	histogram := &dto.Histogram{
+		SampleCount: proto64(count),
+		SampleSum:   &sum,
+		Bucket:      make([]*dto.Bucket, len(buckets)),
+	}
+
+	cumulative := make([]uint64, len(buckets))
+	if count > 0 {
+		for i, cdf := range t.CDFs(buckets) {
+			cumulative[i] = uint64(math.Round(cdf * float64(count)))
+		}
+	}
+
+	for i, bound := range buckets {
+		bound := bound
+		histogram.Bucket[i] = &dto.Bucket{
+			UpperBound:      &bound,
+			CumulativeCount: proto64(cumulative[i]),
+		}
+	}
+	return histogram
+}
+
+// NewFromPrometheusHistogram creates a digest with the given options
+// holding the samples of a Prometheus histogram, so that quantiles
+// other than the bucket boundaries can be estimated.
+//
+// The samples of each bucket (its cumulative count minus the previous
+// one) are added at once, at the midpoint of the bucket. Following
+// the conventions of PromQL's histogram_quantile, the first bucket
+// starts at 0 if its upper bound is positive (at the bound itself
+// otherwise) and the samples above the highest finite bound, either
+// from a +Inf bucket or from a sample count above the last cumulative
+// count, are placed at that bound. Empty and zero-width buckets are
+// fine.
+//
+// This will emit an error if the options are invalid, if h is nil or
+// has no finite bucket while holding samples, or if the buckets aren't
+// sorted by upper bound with non-decreasing cumulative counts.
+func NewFromPrometheusHistogram(h *dto.Histogram, options ...tdigest.Option) (*tdigest.TDigest, error) {
+	if h == nil {
+		return nil, errors.New("histogram must not be nil")
+	}
+
+	t, err := tdigest.New(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	lower := math.Inf(-1)
+	previous := uint64(0)
+	for i, bucket := range h.GetBucket() {
+		upper, cumulative := bucket.GetUpperBound(), bucket.GetCumulativeCount()
+		if math.IsNaN(upper) || upper < lower || upper == math.Inf(-1) {
+			return nil, fmt.Errorf("bucket %d: invalid upper bound %g", i, upper)
+		}
+		if cumulative < previous {
+			return nil, fmt.Errorf("bucket %d: cumulative count %d is lower than the previous one", i, cumulative)
+		}
+		if math.IsInf(upper, 1) {
+			break
+		}
+
+		if math.IsInf(lower, -1) {
+			lower = math.Min(0, upper)
+		}
+		if count := cumulative - previous; count > 0 {
+			err = t.AddWeighted(lower+(upper-lower)/2, count)
+			if err != nil {
+				return nil, err
+			}
+		}
+		lower, previous = upper, cumulative
+	}
+
+	// The +Inf bucket is implicit in the sample count
+	total := h.GetSampleCount()
+	for _, bucket := range h.GetBucket() {
+		if math.IsInf(bucket.GetUpperBound(), 1) && bucket.GetCumulativeCount() > total {
+			total = bucket.GetCumulativeCount()
+		}
+	}
+	if total > previous {
+		if math.IsInf(lower, -1) {
+			return nil, errors.New("can't place the samples of a histogram without finite buckets")
+		}
+		err = t.AddWeighted(lower, total-previous)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func proto64(v uint64) *uint64 {
+	return &v
+}
@@ -0,0 +1,204 @@
+package prometheus
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/caio/go-tdigest/v4"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestToPrometheusHistogram(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	digest, _ := tdigest.New()
+	sum := 0.0
+	for i := 0; i < 10000; i++ {
+		value := rng.Float64()
+		sum += value
+		_ = digest.Add(value)
+	}
+
+	buckets := []float64{0.1, 0.25, 0.5, 0.75, 0.9, math.Inf(1)}
+	histogram := ToPrometheusHistogram(digest, buckets)
+
+	// Round-trip through the wire format
+	data, err := proto.Marshal(histogram)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := &dto.Histogram{}
+	err = proto.Unmarshal(data, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.GetSampleCount() != 10000 {
+		t.Errorf("Expected sample count 10000, got %d", decoded.GetSampleCount())
+	}
+
+	if math.Abs(decoded.GetSampleSum()-sum) > 1e-6*sum {
+		t.Errorf("Expected sample sum close to %f, got %f", sum, decoded.GetSampleSum())
+	}
+
+	if len(decoded.GetBucket()) != len(buckets) {
+		t.Fatalf("Expected %d buckets, got %d", len(buckets), len(decoded.GetBucket()))
+	}
+
+	var previous uint64
+	for i, bucket := range decoded.GetBucket() {
+		if bucket.GetUpperBound() != buckets[i] {
+			t.Errorf("Expected bound %f, got %f", buckets[i], bucket.GetUpperBound())
+		}
+
+		count := bucket.GetCumulativeCount()
+		if count < previous {
+			t.Errorf("Expected cumulative counts, got %d after %d", count, previous)
+		}
+		previous = count
+
+		expected := math.Min(buckets[i], 1) * 10000
+		if math.Abs(float64(count)-expected) > 100 {
+			t.Errorf("Bucket le=%f: expected about %.0f, got %d", buckets[i], expected, count)
+		}
+	}
+
+	if previous != 10000 {
+		t.Errorf("Expected the +Inf bucket to hold all samples, got %d", previous)
+	}
+}
+
+func TestToPrometheusHistogramEmpty(t *testing.T) {
+	digest, _ := tdigest.New()
+	histogram := ToPrometheusHistogram(digest, []float64{1, 2})
+
+	if histogram.GetSampleCount() != 0 || histogram.GetSampleSum() != 0 {
+		t.Errorf("Expected an empty histogram")
+	}
+
+	for _, bucket := range histogram.GetBucket() {
+		if bucket.GetCumulativeCount() != 0 {
+			t.Errorf("Expected empty buckets")
+		}
+	}
+}
+
+func TestToPrometheusHistogramInvalidBuckets(t *testing.T) {
+	digest, _ := tdigest.New()
+
+	for _, buckets := range [][]float64{{2, 1}, {1, math.NaN()}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected a panic for buckets %v", buckets)
+				}
+			}()
+			ToPrometheusHistogram(digest, buckets)
+		}()
+	}
+}
+
+// Exponentially distributed latencies with a mean of 100ms: 100000
+// samples in 50ms buckets, 5 of them above 1s
+func exponentialHistogram() *dto.Histogram {
+	cumulative := []uint64{
+		39347, 63212, 77687, 86466, 91792, 95021, 96980, 98168, 98889, 99326,
+		99591, 99752, 99850, 99909, 99945, 99966, 99980, 99988, 99993, 99995,
+	}
+
+	histogram := &dto.Histogram{SampleCount: proto64(100000)}
+	for i, count := range cumulative {
+		bound := 0.05 * float64(i+1)
+		histogram.Bucket = append(histogram.Bucket, &dto.Bucket{
+			UpperBound:      &bound,
+			CumulativeCount: proto64(count),
+		})
+	}
+	return histogram
+}
+
+func TestNewFromPrometheusHistogram(t *testing.T) {
+	digest, err := NewFromPrometheusHistogram(exponentialHistogram())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digest.Count() != 100000 {
+		t.Errorf("Expected 100000 samples, got %d", digest.Count())
+	}
+
+	// -ln(1 - q) * mean
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		expected := -math.Log(1-q) * 0.1
+		if got := digest.Quantile(q); math.Abs(got-expected) > 0.1*expected {
+			t.Errorf("Expected Quantile(%.2f) within 10%% of %f, got %f", q, expected, got)
+		}
+	}
+
+	// Samples above the last bound are placed at it
+	if digest.Max() != 1 {
+		t.Errorf("Expected the maximum at the last finite bound, got %f", digest.Max())
+	}
+
+	// An explicit +Inf bucket is the same as the implicit one
+	histogram := exponentialHistogram()
+	inf := math.Inf(1)
+	histogram.Bucket = append(histogram.Bucket, &dto.Bucket{UpperBound: &inf, CumulativeCount: proto64(100000)})
+	explicit, err := NewFromPrometheusHistogram(histogram, tdigest.Compression(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explicit.Checksum() != digest.Checksum() {
+		t.Errorf("Expected the +Inf bucket to make no difference")
+	}
+}
+
+func TestNewFromPrometheusHistogramEdgeCases(t *testing.T) {
+	bucket := func(bound float64, count uint64) *dto.Bucket {
+		return &dto.Bucket{UpperBound: &bound, CumulativeCount: proto64(count)}
+	}
+
+	// Negative, empty and zero-width buckets
+	digest, err := NewFromPrometheusHistogram(&dto.Histogram{
+		SampleCount: proto64(30),
+		Bucket:      []*dto.Bucket{bucket(-1, 10), bucket(1, 10), bucket(1, 20), bucket(3, 30)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var centroids []tdigest.Centroid
+	digest.ForEachCentroid(func(mean float64, count uint64) bool {
+		centroids = append(centroids, tdigest.Centroid{Mean: mean, Count: count})
+		return true
+	})
+	expected := []tdigest.Centroid{{Mean: -1, Count: 10}, {Mean: 1, Count: 10}, {Mean: 2, Count: 10}}
+	if !reflect.DeepEqual(centroids, expected) {
+		t.Errorf("Expected centroids %v, got %v", expected, centroids)
+	}
+
+	empty, err := NewFromPrometheusHistogram(&dto.Histogram{Bucket: []*dto.Bucket{bucket(1, 0)}})
+	if err != nil || empty.Count() != 0 {
+		t.Errorf("Expected an empty digest, got %v", err)
+	}
+
+	for name, histogram := range map[string]*dto.Histogram{
+		"nil":              nil,
+		"unsorted":         {Bucket: []*dto.Bucket{bucket(2, 1), bucket(1, 2)}},
+		"decreasing":       {Bucket: []*dto.Bucket{bucket(1, 2), bucket(2, 1)}},
+		"NaN":              {Bucket: []*dto.Bucket{bucket(math.NaN(), 1)}},
+		"only +Inf":        {SampleCount: proto64(1), Bucket: []*dto.Bucket{bucket(math.Inf(1), 1)}},
+		"no buckets":       {SampleCount: proto64(1)},
+		"-Inf upper bound": {Bucket: []*dto.Bucket{bucket(math.Inf(-1), 1)}},
+	} {
+		if _, err := NewFromPrometheusHistogram(histogram); err == nil {
+			t.Errorf("Expected error for a %s histogram", name)
+		}
+	}
+
+	if _, err := NewFromPrometheusHistogram(exponentialHistogram(), tdigest.Compression(0)); err == nil {
+		t.Errorf("Expected error for invalid options")
+	}
+}
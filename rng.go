@@ -11,6 +11,10 @@ type RNG interface {
 	Intn(int) int
 }
 
+// TDigestRNG is an alias of RNG, for code that reads better with
+// the package name spelled out.
+type TDigestRNG = RNG
+
 type globalRNG struct{}
 
 func (r globalRNG) Float32() float32 {
@@ -23,11 +27,13 @@ func (r globalRNG) Intn(i int) int {
 
 type localRNG struct {
 	localRand *rand.Rand
+	seed      int64
 }
 
 func newLocalRNG(seed int64) *localRNG {
 	return &localRNG{
 		localRand: rand.New(rand.NewSource(seed)),
+		seed:      seed,
 	}
 }
 
@@ -38,3 +44,13 @@ func (r *localRNG) Float32() float32 {
 func (r *localRNG) Intn(i int) int {
 	return r.localRand.Intn(i)
 }
+
+// Returns an independent instance of rng when possible. The global
+// RNG is safe to share and custom implementations can't be copied,
+// so they are returned as is.
+func cloneRNG(rng RNG) RNG {
+	if r, ok := rng.(*localRNG); ok {
+		return newLocalRNG(r.seed)
+	}
+	return rng
+}
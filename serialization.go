@@ -2,14 +2,26 @@ package tdigest
 
 import (
 	"bytes"
+	"database/sql/driver"
+	"encoding/base64"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 )
 
 const smallEncoding int32 = 2
 
+// The format version of the digests created by this package, see
+// TDigest.Version
+const currentVersion = int(smallEncoding)
+
 var endianess = binary.BigEndian
 
 // AsBytes serializes the digest into a byte array so it can be
@@ -77,6 +89,7 @@ func FromBytes(buf *bytes.Reader, options ...tdigestOption) (*TDigest, error) {
 	if err != nil {
 		return nil, err
 	}
+	t.version = int(encoding)
 
 	var compression float64
 	err = binary.Read(buf, endianess, &compression)
@@ -123,6 +136,154 @@ func FromBytes(buf *bytes.Reader, options ...tdigestOption) (*TDigest, error) {
 	return t, nil
 }
 
+// NewFromReader reads a serialized digest (from AsBytes or WriteTo)
+// from r and deserializes it. Unlike FromBytes, the payload doesn't
+// have to be buffered first, so it works directly on network
+// connections, files, pipes, etc.
+//
+// Like FromBytes, this creates a new tdigest instance with the
+// provided options, but ignores the compression setting since the
+// correct value comes from the stream. Refer to ReadFrom for details
+// on how r is consumed.
+func NewFromReader(r io.Reader, options ...tdigestOption) (*TDigest, error) {
+	t, err := newWithoutSummary(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = t.ReadFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// SaveToFile serializes the digest (like ToBytes) into the file at
+// path, replacing it if it exists.
+//
+// The data is first written to a temporary file in the same directory
+// which is then renamed to path, so a crash never leaves a partially
+// written file behind: path holds either the previous content or the
+// new one. The file is created with 0600 permissions.
+func (t *TDigest) SaveToFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("saving digest to %s: %w", path, err)
+	}
+	defer func() {
+		// Only effective if renaming didn't happen
+		_ = os.Remove(tmp.Name())
+	}()
+
+	_, err = tmp.Write(t.ToBytes(nil))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("saving digest to %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadFromFile deserializes the digest stored in the file at path, as
+// written by SaveToFile (or any other serialization from AsBytes).
+//
+// Like FromBytes, this creates a new tdigest instance with the
+// provided options, but ignores the compression setting since the
+// correct value comes from the file.
+func LoadFromFile(path string, options ...tdigestOption) (*TDigest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading digest from %s: %w", path, err)
+	}
+
+	t, err := FromBytes(bytes.NewReader(data), options...)
+	if err != nil {
+		return nil, fmt.Errorf("loading digest from %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// MarshalMany serializes several digests at once, e.g.: for shipping
+// per-shard digests to a coordinator. UnmarshalMany reverses it.
+//
+// The result holds the number of digests (4 bytes), followed by each
+// digest serialized as in ToBytes and prefixed with its length (4
+// bytes). This will emit an error if any of the digests is nil.
+func MarshalMany(digests []*TDigest) ([]byte, error) {
+	if uint64(len(digests)) > math.MaxUint32 {
+		return nil, fmt.Errorf("too many digests: %d", len(digests))
+	}
+
+	size := 4
+	for i, t := range digests {
+		if t == nil {
+			return nil, fmt.Errorf("digest %d is nil", i)
+		}
+		size += 4 + t.requiredSize()
+	}
+
+	b := make([]byte, size)
+	endianess.PutUint32(b, uint32(len(digests)))
+	idx := 4
+	for _, t := range digests {
+		// Serialized in place, since there's room for it
+		n := len(t.ToBytes(b[idx+4:]))
+		endianess.PutUint32(b[idx:], uint32(n))
+		idx += 4 + n
+	}
+	return b[:idx], nil
+}
+
+// UnmarshalMany deserializes the digests serialized by MarshalMany.
+//
+// Like FromBytes, this creates the digests with the provided options,
+// but ignores the compression setting since the correct value comes
+// from the data.
+func UnmarshalMany(data []byte, options ...tdigestOption) ([]*TDigest, error) {
+	if len(data) < 4 {
+		return nil, errors.New("buffer too small for deserialization")
+	}
+	numDigests := endianess.Uint32(data)
+	data = data[4:]
+
+	// Each digest takes at least 20 bytes (length and header), so the
+	// count can't be trusted for preallocating more than that
+	capacity := len(data) / 20
+	if uint64(numDigests) < uint64(capacity) {
+		capacity = int(numDigests)
+	}
+	digests := make([]*TDigest, 0, capacity)
+	for i := uint32(0); i < numDigests; i++ {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("digest %d: %w", i, io.ErrUnexpectedEOF)
+		}
+		size := endianess.Uint32(data)
+		data = data[4:]
+		if uint64(len(data)) < uint64(size) {
+			return nil, fmt.Errorf("digest %d: %w", i, io.ErrUnexpectedEOF)
+		}
+
+		t, err := FromBytes(bytes.NewReader(data[:size]), options...)
+		if err != nil {
+			return nil, fmt.Errorf("digest %d: %w", i, err)
+		}
+		digests = append(digests, t)
+		data = data[size:]
+	}
+
+	if len(data) > 0 {
+		return nil, fmt.Errorf("%d trailing bytes after %d digests", len(data), numDigests)
+	}
+	return digests, nil
+}
+
 // FromBytes deserializes into the supplied TDigest struct, re-using
 // and overwriting any existing buffers.
 //
@@ -130,6 +291,10 @@ func FromBytes(buf *bytes.Reader, options ...tdigestOption) (*TDigest, error) {
 // discarding any previously collected data. Notice that in case
 // of errors this may leave the digest in a unusable state.
 func (t *TDigest) FromBytes(buf []byte) error {
+	if t.frozen {
+		return ErrFrozen
+	}
+
 	if len(buf) < 16 {
 		return errors.New("buffer too small for deserialization")
 	}
@@ -138,6 +303,7 @@ func (t *TDigest) FromBytes(buf []byte) error {
 	if encoding != smallEncoding {
 		return fmt.Errorf("unsupported encoding version: %d", encoding)
 	}
+	t.version = int(encoding)
 
 	compression := math.Float64frombits(endianess.Uint64(buf[4:12]))
 	numCentroids := int(endianess.Uint32(buf[12:16]))
@@ -183,6 +349,530 @@ func (t *TDigest) FromBytes(buf []byte) error {
 	if idx != len(buf) {
 		return errors.New("buffer has unread data")
 	}
+
+	// Zero-valued digests have no RNG yet: use the global one
+	if t.rng == nil {
+		t.rng = globalRNG{}
+	}
+	return nil
+}
+
+// WriteTo implements io.WriterTo, writing the same serialization as
+// AsBytes directly to w.
+//
+// The data is written in small chunks, so unlike AsBytes the memory
+// required doesn't grow with the size of the digest. Wrap w with a
+// bufio.Writer if it's costly to write to (e.g.: a network connection).
+// Returns the number of bytes written.
+func (t *TDigest) WriteTo(w io.Writer) (int64, error) {
+	var (
+		buf     [512]byte
+		idx     int
+		written int64
+	)
+
+	flush := func() error {
+		n, err := w.Write(buf[:idx])
+		written += int64(n)
+		idx = 0
+		return err
+	}
+
+	endianess.PutUint32(buf[0:4], uint32(smallEncoding))
+	endianess.PutUint64(buf[4:12], math.Float64bits(t.compression))
+	endianess.PutUint32(buf[12:16], uint32(t.summary.Len()))
+	idx = 16
+
+	var x float64
+	for _, mean := range t.summary.means {
+		if idx+4 > len(buf) {
+			if err := flush(); err != nil {
+				return written, err
+			}
+		}
+		delta := mean - x
+		x = mean
+		endianess.PutUint32(buf[idx:], math.Float32bits(float32(delta)))
+		idx += 4
+	}
+
+	for _, count := range t.summary.counts {
+		if idx+binary.MaxVarintLen64 > len(buf) {
+			if err := flush(); err != nil {
+				return written, err
+			}
+		}
+		idx += binary.PutUvarint(buf[idx:], count)
+	}
+
+	err := flush()
+	return written, err
+}
+
+// ReadFrom implements io.ReaderFrom, reinitializing the digest from
+// the output of WriteTo (or AsBytes) read from r.
+//
+// Contrary to the usual io.ReaderFrom behaviour, r is not read until
+// EOF: reading stops right after the serialized digest, so several
+// digests can be read in sequence from the same stream. This works
+// with any io.Reader, but since the counts must be read byte by byte
+// it's much faster if r also implements io.ByteReader (like
+// bufio.Reader does). Returns the number of bytes read.
+//
+// Like the FromBytes method, this discards any previously collected
+// data and may leave the digest in an unusable state on errors.
+func (t *TDigest) ReadFrom(r io.Reader) (int64, error) {
+	if t.frozen {
+		return 0, ErrFrozen
+	}
+
+	cr := &countingReader{r: r}
+	if br, ok := r.(io.ByteReader); ok {
+		cr.br = br
+	}
+
+	var header [16]byte
+	_, err := io.ReadFull(cr, header[:])
+	if err != nil {
+		return cr.n, err
+	}
+
+	encoding := int32(endianess.Uint32(header[0:4]))
+	if encoding != smallEncoding {
+		return cr.n, fmt.Errorf("unsupported encoding version: %d", encoding)
+	}
+	t.version = int(encoding)
+
+	compression := math.Float64frombits(endianess.Uint64(header[4:12]))
+	numCentroids := int(endianess.Uint32(header[12:16]))
+	if numCentroids < 0 || numCentroids > 1<<22 {
+		return cr.n, errors.New("bad number of centroids in serialization")
+	}
+
+	t.count = 0
+	t.compression = compression
+	if t.summary == nil ||
+		cap(t.summary.means) < numCentroids ||
+		cap(t.summary.counts) < numCentroids {
+		t.summary = newSummary(numCentroids)
+	}
+	t.summary.means = t.summary.means[:numCentroids]
+	t.summary.counts = t.summary.counts[:numCentroids]
+
+	var (
+		buf [512]byte
+		x   float64
+	)
+	for i := 0; i < numCentroids; {
+		chunk := buf[:]
+		if remaining := 4 * (numCentroids - i); remaining < len(chunk) {
+			chunk = chunk[:remaining]
+		}
+		_, err = io.ReadFull(cr, chunk)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return cr.n, err
+		}
+
+		for idx := 0; idx < len(chunk); idx += 4 {
+			x += float64(math.Float32frombits(endianess.Uint32(chunk[idx:])))
+			t.summary.means[i] = x
+			i++
+		}
+	}
+
+	for i := 0; i < numCentroids; i++ {
+		count, err := binary.ReadUvarint(cr)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return cr.n, err
+		}
+		t.summary.counts[i] = count
+		t.count += count
+	}
+
+	if t.rng == nil {
+		t.rng = globalRNG{}
+	}
+	return cr.n, nil
+}
+
+// Counts the bytes read, reading one byte at a time when the
+// underlying reader doesn't implement io.ByteReader.
+type countingReader struct {
+	r   io.Reader
+	br  io.ByteReader
+	n   int64
+	one [1]byte
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	if c.br != nil {
+		b, err := c.br.ReadByte()
+		if err == nil {
+			c.n++
+		}
+		return b, err
+	}
+
+	_, err := io.ReadFull(c, c.one[:])
+	return c.one[0], err
+}
+
+// AppendRecord writes a single observation (as in AddWeighted) to w as
+// a compact binary record: the value as a float64 followed by the
+// count as a uvarint, usually 9 bytes in total. Use ReplayLog to add
+// the recorded observations to a digest.
+//
+// This allows persisting a digest as a log of observations (e.g.: a
+// write-ahead log or an append-only file) instead of snapshotting the
+// whole digest on every change. Values are stored in full rather than
+// as deltas from the previous record: this keeps records independent
+// of each other, so writers may append to a log at any point, and
+// replaying a log yields exactly the same digest as the direct calls.
+//
+// This will emit an error if the observation is invalid (as in
+// AddWeighted) or if writing to w fails.
+func AppendRecord(w io.Writer, value float64, count uint64) error {
+	if err := validSample(value, count); err != nil {
+		return err
+	}
+
+	var record [8 + binary.MaxVarintLen64]byte
+	endianess.PutUint64(record[:], math.Float64bits(value))
+	n := binary.PutUvarint(record[8:], count)
+	_, err := w.Write(record[:8+n])
+	return err
+}
+
+// ReplayLog reads the records written by AppendRecord from r until EOF
+// and adds them to t, in order. Returns the number of bytes read.
+//
+// A log that ends in the middle of a record (e.g.: after a crash while
+// appending to it) yields io.ErrUnexpectedEOF, with all the preceding
+// records added to t: the returned count then marks where the valid
+// data ends. Wrap r with a bufio.Reader if it's costly to read from.
+func ReplayLog(r io.Reader, t *TDigest) (int64, error) {
+	cr := &countingReader{r: r}
+	if br, ok := r.(io.ByteReader); ok {
+		cr.br = br
+	}
+
+	var value [8]byte
+	for {
+		start := cr.n
+
+		_, err := io.ReadFull(cr, value[:])
+		if err == io.EOF {
+			return cr.n, nil
+		}
+		if err != nil {
+			return start, err
+		}
+
+		count, err := binary.ReadUvarint(cr)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return start, err
+		}
+
+		err = t.AddWeighted(math.Float64frombits(endianess.Uint64(value[:])), count)
+		if err != nil {
+			return start, err
+		}
+	}
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, so digests can
+// be used transparently with encoding/gob and other codecs. It's
+// equivalent to AsBytes.
+func (t TDigest) MarshalBinary() ([]byte, error) {
+	return t.AsBytes()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, reinitializing
+// the digest from the output of MarshalBinary (or AsBytes).
+//
+// Like the FromBytes method, this discards any previously collected
+// data and may leave the digest in an unusable state on errors.
+func (t *TDigest) UnmarshalBinary(data []byte) error {
+	return t.FromBytes(data)
+}
+
+// GobEncode implements gob.GobEncoder, so digests can be embedded in
+// values transmitted via encoding/gob (and thus net/rpc). It's
+// equivalent to AsBytes.
+func (t TDigest) GobEncode() ([]byte, error) {
+	return t.AsBytes()
+}
+
+// GobDecode implements gob.GobDecoder, reinitializing the digest from
+// the output of GobEncode. Refer to UnmarshalBinary for more details.
+func (t *TDigest) GobDecode(data []byte) error {
+	return t.UnmarshalBinary(data)
+}
+
+// Value implements driver.Valuer, so digests can be stored directly
+// in binary columns (e.g.: BYTEA, BLOB) via database/sql. It's
+// equivalent to AsBytes.
+func (t TDigest) Value() (driver.Value, error) {
+	return t.AsBytes()
+}
+
+// Scan implements sql.Scanner, reinitializing the digest from a
+// column written via Value. Both []byte and string sources are
+// accepted; NULL is not, use a nullable wrapper for that.
+//
+// Like the FromBytes method, this discards any previously collected
+// data and may leave the digest in an unusable state on errors.
+func (t *TDigest) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return t.UnmarshalBinary(v)
+	case string:
+		return t.UnmarshalBinary([]byte(v))
+	default:
+		return fmt.Errorf("cannot scan %T into a TDigest", src)
+	}
+}
+
+// Prefixes the output of MarshalText so that it can be recognized as
+// a digest at a glance.
+const textPrefix = "tdigest:"
+
+// MarshalText implements encoding.TextMarshaler by encoding the
+// binary serialization (see AsBytes) with standard base64, prefixed
+// with "tdigest:".
+//
+// This allows storing digests in text-only systems (configuration
+// files, environment variables, etc) and lets encoders that rely on
+// encoding.TextMarshaler (e.g.: map keys, TOML) use a compact form.
+// Note that encoding/json prefers MarshalJSON for *TDigest values.
+func (t TDigest) MarshalText() ([]byte, error) {
+	b, err := t.AsBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	text := make([]byte, len(textPrefix)+base64.StdEncoding.EncodedLen(len(b)))
+	copy(text, textPrefix)
+	base64.StdEncoding.Encode(text[len(textPrefix):], b)
+	return text, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, reinitializing
+// the digest from the output of MarshalText. The "tdigest:" prefix is
+// optional, so plain base64 payloads are accepted too.
+//
+// Like the FromBytes method, this discards any previously collected
+// data and may leave the digest in an unusable state on errors.
+func (t *TDigest) UnmarshalText(text []byte) error {
+	text = bytes.TrimPrefix(text, []byte(textPrefix))
+
+	b := make([]byte, base64.StdEncoding.DecodedLen(len(text)))
+	n, err := base64.StdEncoding.Decode(b, text)
+	if err != nil {
+		return err
+	}
+
+	return t.UnmarshalBinary(b[:n])
+}
+
+type jsonDigest struct {
+	Compression float64            `json:"compression"`
+	Count       uint64             `json:"count"`
+	Centroids   []Centroid         `json:"centroids,omitempty"`
+	Quantiles   map[string]float64 `json:"quantiles,omitempty"`
+	P50         *float64           `json:"p50,omitempty"`
+	P99         *float64           `json:"p99,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, encoding the digest as a
+// readable object:
+//
+//	{"compression":100,"count":10,"centroids":[{"mean":0.5,"count":3},...],"p50":0.5,"p99":0.99}
+//
+// The p50 and p99 fields are provided for convenience when inspecting
+// the payload and are omitted for empty digests. Computing them carries
+// out any compression deferred by LazyCompress, which is why this is
+// only implemented by *TDigest. Note that this means json.Marshal on
+// a TDigest value (or a TDigest field of a struct passed by value)
+// emits the MarshalText string instead of this object: pass a pointer
+// to get the object. UnmarshalJSON accepts both. Use MarshalText
+// directly if you prefer a compact representation.
+func (t *TDigest) MarshalJSON() ([]byte, error) {
+	out := jsonDigest{
+		Compression: t.compression,
+		Count:       t.count,
+		Centroids:   make([]Centroid, 0, t.summary.Len()),
+	}
+
+	t.summary.ForEach(func(mean float64, count uint64) bool {
+		out.Centroids = append(out.Centroids, Centroid{Mean: mean, Count: count})
+		return true
+	})
+
+	if t.summary.Len() > 0 {
+		p50, p99 := t.Quantile(0.5), t.Quantile(0.99)
+		out.P50, out.P99 = &p50, &p99
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reinitializing the
+// digest from either:
+//
+//   - The centroid list form, as emitted by MarshalJSON, which restores
+//     the digest exactly
+//   - A quantile-only form, mapping quantiles (in the [0, 1] range) to
+//     their values, for receiving pre-computed summaries. E.g.:
+//     {"compression":100,"count":1000,"quantiles":{"0.5":12,"0.99":80}}
+//   - A string holding the output of MarshalText, which is what
+//     encoding/json emits for (non-addressable) TDigest values
+//
+// The quantile-only form is converted via Prefill, treating the lowest
+// and highest given values as the minimum and maximum so that the
+// resulting digest holds (about) `count` samples. The compression may
+// be omitted in this form, in which case the digest keeps its own (the
+// default, for zero-valued digests). Any convenience quantile fields
+// (p50, p99) are ignored.
+//
+// Like FromBytes, this only replaces the centroids and the compression:
+// the rest of the configuration (RNG, options) is kept.
+//
+// This will emit an error if the payload is invalid or the count does
+// not match the sum of the centroid counts. The digest is left
+// untouched on errors.
+func (t *TDigest) UnmarshalJSON(data []byte) error {
+	if t.frozen {
+		return ErrFrozen
+	}
+
+	// The text form, used by encoding/json for TDigest values
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		err := json.Unmarshal(data, &text)
+		if err != nil {
+			return err
+		}
+
+		// UnmarshalText may leave its receiver half-decoded
+		var decoded TDigest
+		err = decoded.UnmarshalText([]byte(text))
+		if err != nil {
+			return err
+		}
+		t.replaceData(&decoded)
+		t.version = decoded.version
+		return nil
+	}
+
+	var in jsonDigest
+	err := json.Unmarshal(data, &in)
+	if err != nil {
+		return err
+	}
+
+	if len(in.Centroids) > 0 && len(in.Quantiles) > 0 {
+		return errors.New("centroids and quantiles are mutually exclusive")
+	}
+
+	if len(in.Quantiles) > 0 {
+		return t.unmarshalQuantiles(in)
+	}
+
+	decoded, err := NewFromCentroids(in.Compression, in.Centroids)
+	if err != nil {
+		return err
+	}
+
+	if decoded.count != in.Count {
+		return fmt.Errorf("count %d doesn't match the centroids (%d)", in.Count, decoded.count)
+	}
+
+	t.replaceData(decoded)
+	return nil
+}
+
+// Makes the digest hold the centroids (and compression) of decoded,
+// keeping its own configuration like FromBytes does.
+func (t *TDigest) replaceData(decoded *TDigest) {
+	t.summary = decoded.summary
+	t.count = decoded.count
+	t.compression = decoded.compression
+	t.needsCompress = false
+
+	// Zero-valued digests have no RNG yet
+	if t.rng == nil {
+		t.rng = globalRNG{}
+	}
+}
+
+func (t *TDigest) unmarshalQuantiles(in jsonDigest) error {
+	type point struct{ q, value float64 }
+
+	points := make([]point, 0, len(in.Quantiles))
+	for key, value := range in.Quantiles {
+		q, err := strconv.ParseFloat(key, 64)
+		if err != nil {
+			return fmt.Errorf("invalid quantile %q", key)
+		}
+		points = append(points, point{q, value})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].q < points[j].q })
+
+	quantiles := make([]float64, 0, len(points)+2)
+	values := make([]float64, 0, len(points)+2)
+	for _, p := range points {
+		quantiles = append(quantiles, p.q)
+		values = append(values, p.value)
+	}
+
+	// Extend the extremities so that no mass is lost
+	if quantiles[0] > 0 {
+		quantiles = append([]float64{0}, quantiles...)
+		values = append([]float64{values[0]}, values...)
+	}
+	if last := len(quantiles) - 1; quantiles[last] < 1 {
+		quantiles = append(quantiles, 1)
+		values = append(values, values[last])
+	}
+
+	// Prefilled with the configuration of this digest, unless it's
+	// zero-valued
+	var decoded *TDigest
+	if t.summary != nil {
+		decoded = t.slice(0, 0)
+	} else {
+		// Can't fail without options
+		decoded, _ = New()
+	}
+	if in.Compression != 0 {
+		err := Compression(in.Compression)(decoded)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := decoded.Prefill(quantiles, values, in.Count)
+	if err != nil {
+		return err
+	}
+
+	t.replaceData(decoded)
 	return nil
 }
 
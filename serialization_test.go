@@ -1,12 +1,25 @@
 package tdigest
 
 import (
+	"bufio"
 	"bytes"
+	"database/sql"
+	"database/sql/driver"
 	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"math"
 	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestEncodeDecode(t *testing.T) {
@@ -103,7 +116,7 @@ func TestSerialization(t *testing.T) {
 func assertSerialization(t *testing.T, t1, t2 *TDigest) {
 	if t1.Count() != t2.Count() ||
 		t1.summary.Len() != t2.summary.Len() ||
-		t1.compression != t2.compression {
+		t1.Compression() != t2.Compression() {
 		t.Errorf("Deserialized to something different. t1=%v t2=%v", t1, t2)
 	}
 
@@ -229,6 +242,835 @@ func TestJavaSmallBytesCompat(t *testing.T) {
 	assertDifferenceSmallerThan(tdigest, 0.99, 0.005, t)
 	assertDifferenceSmallerThan(tdigest, 0.001, 0.001, t)
 	assertDifferenceSmallerThan(tdigest, 0.999, 0.001, t)
+
+	if tdigest.Version() != 2 {
+		t.Errorf("Expected the Java digest to have version 2, got %d", tdigest.Version())
+	}
+
+	var zero TDigest
+	if zero.Version() != 0 {
+		t.Errorf("Expected version 0 for the zero value, got %d", zero.Version())
+	}
+	for _, decode := range []func(*TDigest) error{
+		func(t *TDigest) error { return t.FromBytes(tdigestAsBytes) },
+		func(t *TDigest) error {
+			_, err := t.ReadFrom(bytes.NewReader(tdigestAsBytes))
+			return err
+		},
+	} {
+		var decoded TDigest
+		if err := decode(&decoded); err != nil || decoded.Version() != 2 || decoded.Clone().Version() != 2 {
+			t.Errorf("Expected decoded digests and their clones to have version 2, got %d (%v)", decoded.Version(), err)
+		}
+	}
+
+	if v := uncheckedNew().Version(); v != 2 {
+		t.Errorf("Expected new digests to have the current version, got %d", v)
+	}
+}
+
+func TestTextMarshaling(t *testing.T) {
+	t1, _ := New()
+	for i := 0; i < 100; i++ {
+		_ = t1.Add(rand.Float64())
+	}
+
+	text, err := t1.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serialized, _ := t1.AsBytes()
+	if string(text) != "tdigest:"+base64.StdEncoding.EncodeToString(serialized) {
+		t.Errorf("MarshalText() should return the prefixed base64 encoded binary form")
+	}
+
+	t2 := &TDigest{}
+	err = t2.UnmarshalText(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSerialization(t, t1, t2)
+
+	// Payloads without the prefix are still accepted
+	t3 := &TDigest{}
+	err = t3.UnmarshalText([]byte(base64.StdEncoding.EncodeToString(serialized)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSerialization(t, t1, t3)
+
+	for _, bad := range []string{"not base64!", "tdigest:not base64!", "tdigest:"} {
+		if t2.UnmarshalText([]byte(bad)) == nil {
+			t.Errorf("Expected UnmarshalText(%q) to fail", bad)
+		}
+	}
+}
+
+func TestTextMarshalingInJSON(t *testing.T) {
+	type config struct {
+		Latency  *TDigest `json:"latency"`
+		Baseline TDigest  `json:"baseline"`
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	t1 := uncheckedNew()
+	for i := 0; i < 1000; i++ {
+		_ = t1.Add(rng.ExpFloat64())
+	}
+
+	// Fields of a value are not addressable, so encoding/json can't use
+	// MarshalJSON (pointer receiver) for Baseline and uses MarshalText
+	data, err := json.Marshal(config{Latency: t1, Baseline: *t1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"baseline":"tdigest:`) {
+		t.Errorf("Expected encoding/json to use MarshalText() for TDigest values, got %s", data)
+	}
+	if strings.Contains(string(data), `"latency":"tdigest:`) {
+		t.Errorf("Expected encoding/json to use MarshalJSON() for *TDigest values, got %s", data)
+	}
+
+	var decoded config
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Means are serialized as float32 deltas, so some precision is lost
+	for _, t2 := range []*TDigest{decoded.Latency, &decoded.Baseline} {
+		for _, q := range []float64{0, 0.01, 0.5, 0.99, 1} {
+			if math.Abs(t1.Quantile(q)-t2.Quantile(q)) > 1e-4*t1.Quantile(q) {
+				t.Errorf("Quantile(%.2f) changed after the round-trip: %f != %f", q, t1.Quantile(q), t2.Quantile(q))
+			}
+		}
+	}
+}
+
+func TestJSONMarshaling(t *testing.T) {
+	type wrapper struct {
+		Digest *TDigest `json:"digest"`
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	t1, _ := New()
+	for i := 0; i < 1000; i++ {
+		_ = t1.Add(rng.Float64())
+	}
+
+	data, err := json.MarshalIndent(wrapper{t1}, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields struct {
+		Digest map[string]interface{} `json:"digest"`
+	}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"compression", "count", "centroids", "p50", "p99"} {
+		if _, ok := fields.Digest[key]; !ok {
+			t.Errorf("Expected key %q in %s", key, data)
+		}
+	}
+
+	if fields.Digest["p99"].(float64) != t1.Quantile(0.99) {
+		t.Errorf("Expected p99 to match Quantile(0.99)")
+	}
+
+	var w wrapper
+	err = json.Unmarshal(data, &w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSerialization(t, t1, w.Digest)
+
+	// Empty digests round-trip too
+	empty, _ := New(Compression(10))
+	data, err = json.Marshal(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"compression":10,"count":0}` {
+		t.Errorf("Unexpected encoding for empty digest: %s", data)
+	}
+
+	t2 := &TDigest{}
+	err = json.Unmarshal(data, t2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSerialization(t, empty, t2)
+}
+
+func TestJSONUnmarshalQuantiles(t *testing.T) {
+	var digest TDigest
+	data := `{"count":10000,"quantiles":{"0.25":25,"0.5":50,"0.75":75,"0.1":10,"0.9":90}}`
+
+	err := json.Unmarshal([]byte(data), &digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digest.Count() != 10000 {
+		t.Errorf("Expected count 10000, got %d", digest.Count())
+	}
+
+	if digest.Compression() != 100 {
+		t.Errorf("Expected the default compression, got %f", digest.Compression())
+	}
+
+	if q := digest.Quantile(0.5); math.Abs(q-50) > 5 {
+		t.Errorf("Expected Quantile(0.5) close to 50, got %f", q)
+	}
+
+	for _, bad := range []string{
+		`[]`,
+		`{"compression":0,"count":1,"centroids":[{"mean":1,"count":1}]}`,
+		`{"compression":100,"count":2,"centroids":[{"mean":1,"count":1}]}`,
+		`{"compression":100,"count":1,"centroids":[{"mean":1,"count":1}],"quantiles":{"0.5":1}}`,
+		`{"count":10,"quantiles":{"half":1}}`,
+		`{"count":10,"quantiles":{"0.1":2,"0.9":1}}`,
+	} {
+		before := digest.Count()
+		if json.Unmarshal([]byte(bad), &digest) == nil {
+			t.Errorf("Expected error when unmarshaling %s", bad)
+		}
+		if digest.Count() != before {
+			t.Errorf("Digest modified after failing to unmarshal %s", bad)
+		}
+	}
+}
+
+func TestJSONUnmarshalKeepsConfiguration(t *testing.T) {
+	t1 := uncheckedNew(Compression(50))
+	for i := 0; i < 1000; i++ {
+		_ = t1.Add(float64(i))
+	}
+	data, err := json.Marshal(t1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, _ := t1.MarshalText()
+	quoted, _ := json.Marshal(string(text))
+
+	payloads := [][]byte{data, []byte(`{"count":1000,"quantiles":{"0.5":500}}`), quoted}
+	for _, payload := range payloads {
+		rng := &countingRNG{r: rand.New(rand.NewSource(0xCA10))}
+		t2 := uncheckedNew(CustomRNG(rng), MaxCentroids(300), LazyCompress())
+		if err := json.Unmarshal(payload, t2); err != nil {
+			t.Fatal(err)
+		}
+
+		if t2.rng != rng || t2.maxCentroids != 300 || !t2.lazyCompress {
+			t.Errorf("Expected the configuration to be kept when unmarshaling %s", payload)
+		}
+		if t2.Count() != 1000 {
+			t.Errorf("Expected 1000 samples, got %d", t2.Count())
+		}
+	}
+
+	// A truncated text payload leaves the digest untouched
+	serialized, _ := t1.AsBytes()
+	truncated, _ := json.Marshal(textPrefix + base64.StdEncoding.EncodeToString(serialized[:len(serialized)-3]))
+	t2 := uncheckedNew()
+	_ = t2.Add(42)
+	before := t2.Checksum()
+	if err := json.Unmarshal(truncated, t2); err == nil {
+		t.Fatalf("Expected error for a truncated payload")
+	}
+	if t2.Checksum() != before || t2.Count() != 1 {
+		t.Errorf("Expected the digest to be untouched after an error")
+	}
+}
+
+func TestJSONRoundTripQuantiles(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	t1, _ := New(Compression(200))
+	for i := 0; i < 10000; i++ {
+		_ = t1.Add(rng.ExpFloat64())
+	}
+
+	data, err := json.Marshal(t1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A zero value digest becomes fully functional
+	var t2 TDigest
+	err = json.Unmarshal(data, &t2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []float64{0, 0.001, 0.1, 0.5, 0.9, 0.999, 1} {
+		if !closeEnough(t1.Quantile(q), t2.Quantile(q)) {
+			t.Errorf("Quantile(%.3f) changed after the round-trip: %f != %f", q, t1.Quantile(q), t2.Quantile(q))
+		}
+	}
+
+	if _, ok := t2.rng.(globalRNG); !ok {
+		t.Fatalf("Expected the global RNG to be used, got %T", t2.rng)
+	}
+
+	err = t2.Merge(t1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if t2.Count() != 2*t1.Count() {
+		t.Errorf("Expected count %d after merging, got %d", 2*t1.Count(), t2.Count())
+	}
+}
+
+func TestBinaryMarshalingGob(t *testing.T) {
+	type wrapper struct {
+		Name   string
+		Digest *TDigest
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	t1, _ := New(Compression(50))
+	for i := 0; i < 1000; i++ {
+		_ = t1.Add(rng.Float64())
+	}
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(wrapper{"latency", t1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var w wrapper
+	err = gob.NewDecoder(&buf).Decode(&w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Name != "latency" {
+		t.Errorf("Unexpected name %q", w.Name)
+	}
+	assertSerialization(t, t1, w.Digest)
+
+	data, _ := t1.MarshalBinary()
+	serialized, _ := t1.AsBytes()
+	if !bytes.Equal(data, serialized) {
+		t.Errorf("Expected MarshalBinary() to match AsBytes()")
+	}
+
+	var t2 TDigest
+	if t2.UnmarshalBinary([]byte{0x1, 0x2}) == nil {
+		t.Errorf("Expected UnmarshalBinary() to fail with bad input")
+	}
+}
+
+func TestWriteToReadFrom(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	t1, _ := New(Compression(1000))
+	for i := 0; i < 100000; i++ {
+		_ = t1.Add(rng.NormFloat64())
+	}
+	t2, _ := New(Compression(10))
+	for i := 0; i < 100; i++ {
+		_ = t2.Add(rng.Float64())
+	}
+
+	var buf bytes.Buffer
+	for _, digest := range []*TDigest{t1, t2} {
+		n, err := digest.WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		serialized, _ := digest.AsBytes()
+		if n != int64(len(serialized)) {
+			t.Errorf("WriteTo() reported %d bytes, expected %d", n, len(serialized))
+		}
+		if !bytes.Equal(buf.Bytes()[buf.Len()-int(n):], serialized) {
+			t.Errorf("Expected WriteTo() to match AsBytes()")
+		}
+	}
+	stream := buf.Bytes()
+
+	readers := map[string]func() io.Reader{
+		"bytes":    func() io.Reader { return bytes.NewReader(stream) },
+		"bufio":    func() io.Reader { return bufio.NewReader(bytes.NewReader(stream)) },
+		"one byte": func() io.Reader { return iotest.OneByteReader(bytes.NewReader(stream)) },
+	}
+
+	for name, reader := range readers {
+		r := reader()
+		var total int64
+
+		// Digests are read in sequence from the same stream
+		for _, expected := range []*TDigest{t1, t2} {
+			var decoded TDigest
+			n, err := decoded.ReadFrom(r)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			total += n
+			assertSerialization(t, expected, &decoded)
+		}
+
+		if total != int64(len(stream)) {
+			t.Errorf("%s: ReadFrom() reported %d bytes, expected %d", name, total, len(stream))
+		}
+
+		var decoded TDigest
+		if _, err := decoded.ReadFrom(r); err != io.EOF {
+			t.Errorf("%s: Expected io.EOF at the end of the stream, got %v", name, err)
+		}
+	}
+
+	for _, size := range []int{8, 20, len(stream)/2 - 2} {
+		var decoded TDigest
+		if _, err := decoded.ReadFrom(bytes.NewReader(stream[:size])); err == nil {
+			t.Errorf("Expected error reading a truncated stream of %d bytes", size)
+		}
+	}
+
+	if _, err := t1.WriteTo(failingWriter{}); err == nil {
+		t.Errorf("Expected writer errors to be propagated")
+	}
+}
+
+func TestGobEncoding(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	t1, _ := New(Compression(100))
+	for i := 0; i < 10000; i++ {
+		_ = t1.Add(rng.ExpFloat64())
+	}
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(t1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var t2 TDigest
+	err = gob.NewDecoder(&buf).Decode(&t2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Means are serialized as float32 deltas, so some precision is lost
+	for _, q := range []float64{0, 0.01, 0.5, 0.99, 1} {
+		if math.Abs(t1.Quantile(q)-t2.Quantile(q)) > 1e-4*t1.Quantile(q) {
+			t.Errorf("Quantile(%.2f) changed after the round-trip: %f != %f", q, t1.Quantile(q), t2.Quantile(q))
+		}
+	}
+
+	assertSerialization(t, t1, &t2)
+
+	data, _ := t1.GobEncode()
+	serialized, _ := t1.AsBytes()
+	if !bytes.Equal(data, serialized) {
+		t.Errorf("Expected GobEncode() to match AsBytes()")
+	}
+
+	if t2.GobDecode([]byte{0x1}) == nil {
+		t.Errorf("Expected GobDecode() to fail with bad input")
+	}
+}
+
+// A minimal database/sql driver backed by a single in-memory blob:
+// any statement with an argument stores it, any without reads it back.
+type blobDriver struct {
+	blob driver.Value
+}
+
+func (d *blobDriver) Open(string) (driver.Conn, error) { return blobConn{d}, nil }
+
+type blobConn struct{ d *blobDriver }
+
+func (c blobConn) Prepare(query string) (driver.Stmt, error) { return blobStmt(c), nil }
+func (c blobConn) Close() error                              { return nil }
+func (c blobConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type blobStmt struct{ d *blobDriver }
+
+func (s blobStmt) Close() error  { return nil }
+func (s blobStmt) NumInput() int { return -1 }
+
+func (s blobStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.blob = args[0]
+	return driver.RowsAffected(1), nil
+}
+
+func (s blobStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &blobRows{blob: s.d.blob}, nil
+}
+
+type blobRows struct {
+	blob driver.Value
+	done bool
+}
+
+func (r *blobRows) Columns() []string { return []string{"digest"} }
+func (r *blobRows) Close() error      { return nil }
+
+func (r *blobRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.blob
+	return nil
+}
+
+// Registered once: sql.Register panics on duplicates, e.g. with -count
+func init() {
+	sql.Register("tdigest-blob", &blobDriver{})
+}
+
+func TestSQLRoundTrip(t *testing.T) {
+	db, err := sql.Open("tdigest-blob", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	t1 := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = t1.Add(rng.ExpFloat64())
+	}
+
+	_, err = db.Exec("INSERT INTO digests VALUES (?)", t1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var t2 TDigest
+	err = db.QueryRow("SELECT digest FROM digests").Scan(&t2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Means are serialized as float32 deltas, so some precision is lost
+	for _, q := range []float64{0, 0.01, 0.5, 0.99, 1} {
+		if math.Abs(t1.Quantile(q)-t2.Quantile(q)) > 1e-4*t1.Quantile(q) {
+			t.Errorf("Quantile(%.2f) changed after the round-trip: %f != %f", q, t1.Quantile(q), t2.Quantile(q))
+		}
+	}
+
+	serialized, _ := t1.AsBytes()
+	var t3 TDigest
+	if err := t3.Scan(string(serialized)); err != nil || t3.Count() != t1.Count() {
+		t.Errorf("Expected Scan() to accept strings, got %v", err)
+	}
+
+	for _, src := range []interface{}{nil, 42, []byte{0x1}} {
+		if t3.Scan(src) == nil {
+			t.Errorf("Expected Scan(%#v) to fail", src)
+		}
+	}
+}
+
+func TestNewFromReader(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	t1 := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = t1.Add(rng.ExpFloat64())
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := t1.WriteTo(pw)
+		pw.CloseWithError(err)
+	}()
+
+	t2, err := NewFromReader(pr, Compression(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if t2.Compression() != t1.Compression() || t2.Count() != t1.Count() {
+		t.Errorf("Expected the compression and count to come from the stream")
+	}
+
+	// Means are serialized as float32 deltas, so some precision is lost
+	for _, q := range []float64{0, 0.01, 0.5, 0.99, 1} {
+		if math.Abs(t1.Quantile(q)-t2.Quantile(q)) > 1e-4*t1.Quantile(q) {
+			t.Errorf("Quantile(%.2f) changed after the round-trip: %f != %f", q, t1.Quantile(q), t2.Quantile(q))
+		}
+	}
+
+	serialized, _ := t1.AsBytes()
+	_, err = NewFromReader(bytes.NewReader(serialized[:len(serialized)-1]))
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected ErrUnexpectedEOF for a truncated stream, got %v", err)
+	}
+
+	_, err = NewFromReader(bytes.NewReader(serialized), Compression(0))
+	if err == nil {
+		t.Errorf("Expected error for invalid options")
+	}
+}
+
+// The digest saved by the child process in TestSaveLoadFile
+func fileTestDigest() *TDigest {
+	rng := rand.New(rand.NewSource(0xCA10))
+	digest := uncheckedNew()
+	for i := 0; i < 100000; i++ {
+		_ = digest.Add(rng.ExpFloat64())
+	}
+	return digest
+}
+
+func TestSaveLoadFile(t *testing.T) {
+	// Child process: save and exit
+	if path := os.Getenv("TDIGEST_SAVE_TO"); path != "" {
+		if err := fileTestDigest().SaveToFile(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "digest.bin")
+
+	// Overwritten by the child
+	if err := uncheckedNew().SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSaveLoadFile$")
+	cmd.Env = append(os.Environ(), "TDIGEST_SAVE_TO="+path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Saving from a child process failed: %v\n%s", err, out)
+	}
+
+	loaded, err := LoadFromFile(path, Compression(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := fileTestDigest()
+	if loaded.Count() != digest.Count() || loaded.Compression() != digest.Compression() {
+		t.Errorf("Expected the count and compression to come from the file")
+	}
+	for _, q := range []float64{0, 0.01, 0.5, 0.99, 0.999, 1} {
+		if math.Abs(loaded.Quantile(q)-digest.Quantile(q)) > 0.001*digest.Quantile(q) {
+			t.Errorf("Quantile(%.3f) changed after saving: %f != %f", q, digest.Quantile(q), loaded.Quantile(q))
+		}
+	}
+
+	// No temporary files left behind
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected only the saved file in %s, got %d entries", dir, len(entries))
+	}
+
+	var pathErr *os.PathError
+	if _, err := LoadFromFile(filepath.Join(dir, "missing")); !errors.As(err, &pathErr) || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Expected a wrapped *os.PathError naming the file, got %v", err)
+	}
+	if err := digest.SaveToFile(filepath.Join(dir, "missing", "digest.bin")); !errors.As(err, &pathErr) {
+		t.Errorf("Expected a wrapped *os.PathError, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFromFile(path); err == nil {
+		t.Errorf("Expected error for a corrupted file")
+	}
+}
+
+func TestMarshalMany(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	digests := make([]*TDigest, 100)
+	size := 4
+	for i := range digests {
+		// The first one is empty
+		digests[i] = uncheckedNew(Compression(float64(10 + i)))
+		for j := 0; j < i*100; j++ {
+			_ = digests[i].Add(rng.ExpFloat64() * float64(i))
+		}
+		serialized, _ := digests[i].AsBytes()
+		size += 4 + len(serialized)
+	}
+
+	data, err := MarshalMany(digests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != size {
+		t.Errorf("Expected %d bytes, got %d", size, len(data))
+	}
+
+	restored, err := UnmarshalMany(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != len(digests) {
+		t.Fatalf("Expected %d digests, got %d", len(digests), len(restored))
+	}
+
+	for i, digest := range digests {
+		if restored[i].Count() != digest.Count() || restored[i].Compression() != digest.Compression() {
+			t.Errorf("Digest %d: expected count %d and compression %.0f, got %d and %.0f",
+				i, digest.Count(), digest.Compression(), restored[i].Count(), restored[i].Compression())
+		}
+		// Means are serialized as float32 deltas, so some precision is lost
+		for _, q := range []float64{0, 0.01, 0.5, 0.99, 1} {
+			if math.Abs(digest.Quantile(q)-restored[i].Quantile(q)) > 1e-4*digest.Quantile(q) {
+				t.Errorf("Digest %d: Quantile(%.2f) changed after the round-trip: %f != %f", i, q, digest.Quantile(q), restored[i].Quantile(q))
+			}
+		}
+	}
+
+	if empty, err := MarshalMany(nil); err != nil || len(empty) != 4 {
+		t.Errorf("Expected 4 bytes for no digests, got %d (%v)", len(empty), err)
+	}
+	if _, err := MarshalMany([]*TDigest{digests[1], nil}); err == nil {
+		t.Errorf("Expected error for a nil digest")
+	}
+
+	if _, err := UnmarshalMany(data[:len(data)-1]); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected ErrUnexpectedEOF for truncated data, got %v", err)
+	}
+	if _, err := UnmarshalMany(append(data, 0)); err == nil {
+		t.Errorf("Expected error for trailing data")
+	}
+	if _, err := UnmarshalMany(data, Compression(0)); err == nil {
+		t.Errorf("Expected error for invalid options")
+	}
+}
+
+func TestFromBytesIntoZeroValue(t *testing.T) {
+	t1 := uncheckedNew()
+	for i := 0; i < 1000; i++ {
+		_ = t1.Add(float64(i))
+	}
+	data, _ := t1.AsBytes()
+
+	var t2 TDigest
+	err := t2.FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Used to panic: merging and compressing need an RNG
+	if err := t2.Merge(t1); err != nil {
+		t.Fatal(err)
+	}
+	if err := t2.Compress(); err != nil {
+		t.Fatal(err)
+	}
+	if t2.Count() != 2*t1.Count() {
+		t.Errorf("Expected %d samples, got %d", 2*t1.Count(), t2.Count())
+	}
+}
+
+func TestAppendLog(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	direct := uncheckedNew()
+
+	var log bytes.Buffer
+	for i := 0; i < 10000; i++ {
+		value := rng.ExpFloat64()
+		count := uint64(1 + rng.Intn(3))
+
+		_ = direct.AddWeighted(value, count)
+		err := AppendRecord(&log, value, count)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	size := int64(log.Len())
+	if size != 10000*9 {
+		t.Errorf("Expected 9 bytes per record, got %d bytes in total", size)
+	}
+	data := append([]byte{}, log.Bytes()...)
+
+	replayed := uncheckedNew()
+	n, err := ReplayLog(&log, replayed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != size {
+		t.Errorf("Expected to read %d bytes, got %d", size, n)
+	}
+
+	// Same observations in the same order yield the same digest
+	if replayed.Count() != direct.Count() || replayed.Checksum() != direct.Checksum() {
+		t.Errorf("Expected the replayed digest to match the direct one")
+	}
+	for _, q := range []float64{0, 0.01, 0.5, 0.99, 1} {
+		if replayed.Quantile(q) != direct.Quantile(q) {
+			t.Errorf("Quantile(%.2f) differs: %f != %f", q, replayed.Quantile(q), direct.Quantile(q))
+		}
+	}
+
+	// A torn write at the end of the log
+	truncated := uncheckedNew()
+	n, err = ReplayLog(iotest.OneByteReader(bytes.NewReader(data[:len(data)-2])), truncated)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected ErrUnexpectedEOF for a truncated log, got %v", err)
+	}
+	if n != size-9 || truncated.Len() == 0 {
+		t.Errorf("Expected every complete record to be replayed, read %d bytes", n)
+	}
+
+	n, err = ReplayLog(bytes.NewReader(nil), uncheckedNew())
+	if n != 0 || err != nil {
+		t.Errorf("Expected an empty log to be fine, got %d, %v", n, err)
+	}
+
+	for _, bad := range []struct {
+		value float64
+		count uint64
+	}{{1, 0}, {math.NaN(), 1}, {math.Inf(1), 1}, {math.Inf(-1), 1}} {
+		if AppendRecord(io.Discard, bad.value, bad.count) == nil {
+			t.Errorf("Expected error for value=%g count=%d", bad.value, bad.count)
+		}
+	}
+}
+
+func TestEqualAfterRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	t1 := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = t1.Add(rng.NormFloat64())
+	}
+
+	data, err := json.Marshal(t1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var t2 TDigest
+	err = json.Unmarshal(data, &t2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !t1.Equal(&t2) {
+		t.Errorf("Expected the JSON round-trip to be exact")
+	}
+
+	// Sorted samples aren't merged, so every centroid holds an integer
+	// mean, which float32 deltas represent exactly
+	integers := uncheckedNew()
+	for i := 0; i < 1000; i++ {
+		_ = integers.AddWeighted(float64(i), uint64(1+rng.Intn(10)))
+	}
+	serialized, _ := integers.AsBytes()
+	var t3 TDigest
+	err = t3.FromBytes(serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !integers.Equal(&t3) {
+		t.Errorf("Expected the binary round-trip of integer samples to be exact")
+	}
 }
 
 func BenchmarkAsBytes(b *testing.B) {
@@ -295,3 +1137,32 @@ func BenchmarkFromBytesMethod(b *testing.B) {
 		t2.FromBytes(buf)
 	}
 }
+
+func BenchmarkAsBytesWrite(b *testing.B) {
+	b.ReportAllocs()
+
+	t1, _ := New(Compression(1000))
+	for i := 0; i < 100000; i++ {
+		t1.Add(rand.Float64())
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		data, _ := t1.AsBytes()
+		_, _ = io.Discard.Write(data)
+	}
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+	b.ReportAllocs()
+
+	t1, _ := New(Compression(1000))
+	for i := 0; i < 100000; i++ {
+		t1.Add(rand.Float64())
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = t1.WriteTo(io.Discard)
+	}
+}
@@ -0,0 +1,87 @@
+package tdigest
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// ShardedDigest is a concurrency-safe digest that spreads the samples
+// over several independent shards, each a SyncTDigest.
+//
+// With many goroutines adding samples to a single SyncTDigest, most of
+// the time is spent waiting for its lock. Spreading the samples over
+// N shards (in a round-robin fashion) divides that contention by N.
+// Queries need all the shards merged, via Merge, so this is a good fit
+// for write-heavy workloads that are only queried every now and then,
+// e.g.: when exporting metrics.
+type ShardedDigest struct {
+	// Accessed atomically, must be the first field for alignment
+	next    uint64
+	shards  []*SyncTDigest
+	options []tdigestOption
+}
+
+// NewSharded creates a digest with the given number of shards. The
+// options configure the digest of every shard, as well as the result
+// of Merge, like in New. A good number of shards is usually
+// runtime.GOMAXPROCS(0).
+//
+// This will emit an error if shards <= 0 or if the options are invalid.
+func NewSharded(shards int, options ...tdigestOption) (*ShardedDigest, error) {
+	if shards <= 0 {
+		return nil, fmt.Errorf("shards must be > 0, got %d", shards)
+	}
+
+	s := &ShardedDigest{
+		shards:  make([]*SyncTDigest, shards),
+		options: options,
+	}
+	for i := range s.shards {
+		shard, err := NewSync(options...)
+		if err != nil {
+			return nil, err
+		}
+		s.shards[i] = shard
+	}
+	return s, nil
+}
+
+// AddWeighted registers a new sample in the next shard. Refer to
+// TDigest.AddWeighted for more details.
+func (s *ShardedDigest) AddWeighted(value float64, count uint64) error {
+	idx := atomic.AddUint64(&s.next, 1) % uint64(len(s.shards))
+	return s.shards[idx].AddWeighted(value, count)
+}
+
+// Add is an alias for AddWeighted(x,1)
+func (s *ShardedDigest) Add(value float64) error {
+	return s.AddWeighted(value, 1)
+}
+
+// Merge returns a new digest holding the samples of all the shards.
+//
+// Shards are locked one at a time, so samples added concurrently may
+// or may not make it to the result.
+func (s *ShardedDigest) Merge() (*TDigest, error) {
+	result, err := New(s.options...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, shard := range s.shards {
+		err = shard.mergeInto(result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Count returns the number of samples in all the shards.
+func (s *ShardedDigest) Count() uint64 {
+	var count uint64
+	for _, shard := range s.shards {
+		count += shard.Count()
+	}
+	return count
+}
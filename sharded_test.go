@@ -0,0 +1,106 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestShardedDigest(t *testing.T) {
+	t.Parallel()
+
+	sharded, err := NewSharded(4, Compression(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, iterations = 8, 10000
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < iterations; i++ {
+				_ = sharded.Add(rng.Float64())
+			}
+		}(int64(w))
+	}
+
+	// Merging while samples are being added
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			if _, err := sharded.Merge(); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	if sharded.Count() != writers*iterations {
+		t.Errorf("Expected count %d, got %d", writers*iterations, sharded.Count())
+	}
+
+	for _, shard := range sharded.shards {
+		if shard.Count() == 0 {
+			t.Errorf("Expected the samples to be spread over all the shards")
+		}
+	}
+
+	merged, err := sharded.Merge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if merged.Count() != writers*iterations || merged.Compression() != 42 {
+		t.Errorf("Unexpected merged digest: count=%d compression=%f", merged.Count(), merged.Compression())
+	}
+
+	for _, q := range []float64{0.1, 0.5, 0.9} {
+		if math.Abs(merged.Quantile(q)-q) > 0.01 {
+			t.Errorf("Expected Quantile(%.1f) close to %.1f, got %f", q, q, merged.Quantile(q))
+		}
+	}
+}
+
+func TestNewShardedErrors(t *testing.T) {
+	if _, err := NewSharded(0); err == nil {
+		t.Errorf("Expected error for 0 shards")
+	}
+
+	if _, err := NewSharded(2, Compression(0)); err == nil {
+		t.Errorf("Expected error for invalid options")
+	}
+}
+
+func BenchmarkSyncTDigestParallel(b *testing.B) {
+	b.ReportAllocs()
+
+	digest, _ := NewSync()
+	b.RunParallel(func(pb *testing.PB) {
+		value := 0.0
+		for pb.Next() {
+			_ = digest.Add(value)
+			value += 0.001
+		}
+	})
+}
+
+func BenchmarkShardedDigestParallel(b *testing.B) {
+	b.ReportAllocs()
+
+	sharded, _ := NewSharded(runtime.GOMAXPROCS(0))
+	b.RunParallel(func(pb *testing.PB) {
+		value := 0.0
+		for pb.Next() {
+			_ = sharded.Add(value)
+			value += 0.001
+		}
+	})
+}
@@ -23,10 +23,29 @@ func (s *summary) Len() int {
 	return len(s.means)
 }
 
+// Grows the backing arrays so they can hold at least n items
+// without reallocating.
+func (s *summary) Reserve(n int) {
+	if cap(s.means) >= n && cap(s.counts) >= n {
+		return
+	}
+
+	means := make([]float64, len(s.means), n)
+	copy(means, s.means)
+	s.means = means
+
+	counts := make([]uint64, len(s.counts), n)
+	copy(counts, s.counts)
+	s.counts = counts
+}
+
 func (s *summary) Add(key float64, value uint64) error {
 	if math.IsNaN(key) {
 		return fmt.Errorf("key must not be NaN")
 	}
+	if math.IsInf(key, 0) {
+		return fmt.Errorf("key must not be infinite")
+	}
 	if value == 0 {
 		return fmt.Errorf("Count must be >0")
 	}
@@ -138,6 +157,28 @@ func (s *summary) adjustLeft(index int) {
 	}
 }
 
+// Merges the two adjacent items with the closest means into a single
+// one, placed at their weighted average.
+func (s *summary) mergeNearest() {
+	if len(s.means) < 2 {
+		return
+	}
+
+	nearest := 0
+	for i := 1; i < len(s.means)-1; i++ {
+		if s.means[i+1]-s.means[i] < s.means[nearest+1]-s.means[nearest] {
+			nearest = i
+		}
+	}
+
+	c1, c2 := float64(s.counts[nearest]), float64(s.counts[nearest+1])
+	s.means[nearest] = boundedWeightedAverage(s.means[nearest], c1, s.means[nearest+1], c2)
+	s.counts[nearest] += s.counts[nearest+1]
+
+	s.means = append(s.means[:nearest+1], s.means[nearest+2:]...)
+	s.counts = append(s.counts[:nearest+1], s.counts[nearest+2:]...)
+}
+
 func (s *summary) ForEach(f func(float64, uint64) bool) {
 	for i, mean := range s.means {
 		if !f(mean, s.counts[i]) {
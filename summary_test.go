@@ -20,6 +20,10 @@ func TestBasics(t *testing.T) {
 		t.Errorf("Adding math.NaN() shouldn't be allowed")
 	}
 
+	if s.Add(math.Inf(1), 1) == nil || s.Add(math.Inf(-1), 1) == nil {
+		t.Errorf("Adding infinities shouldn't be allowed")
+	}
+
 	if s.Add(1, 0) == nil {
 		t.Errorf("Adding count=0 shouldn't be allowed")
 	}
@@ -191,3 +195,28 @@ func TestAdjustLeftRight(t *testing.T) {
 		t.Errorf("adjustLeft should have fixed the keys/counts state. %v %v", s.means, s.counts)
 	}
 }
+func TestMergeNearest(t *testing.T) {
+	s := newSummary(10)
+	for _, i := range []float64{1, 2, 4, 4.5, 8} {
+		_ = s.Add(i, 2)
+	}
+
+	s.mergeNearest()
+	checkSorted(s, t)
+
+	if s.Len() != 4 || s.means[2] != 4.25 || s.counts[2] != 4 {
+		t.Errorf("Expected {4,8} and {4.5,9} to be merged. Got %v %v", s.means, s.counts)
+	}
+
+	s.mergeNearest()
+	if s.Len() != 3 || s.means[0] != 1.5 || s.counts[0] != 4 {
+		t.Errorf("Expected {1,2} and {2,4} to be merged. Got %v %v", s.means, s.counts)
+	}
+
+	s = newSummary(1)
+	_ = s.Add(1, 1)
+	s.mergeNearest()
+	if s.Len() != 1 {
+		t.Errorf("mergeNearest() should do nothing with a single item")
+	}
+}
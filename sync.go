@@ -0,0 +1,145 @@
+package tdigest
+
+import "sync"
+
+// SyncTDigest is a TDigest that is safe for concurrent use.
+//
+// A plain TDigest must not be shared across goroutines without
+// synchronization (even Merge mutates the receiver's RNG state).
+// SyncTDigest guards a digest with a sync.RWMutex: operations that
+// modify it take the write lock while queries share the read lock.
+// Its methods mirror the ones from TDigest, so it can be swapped in
+// with minimal changes.
+type SyncTDigest struct {
+	mu     sync.RWMutex
+	digest *TDigest
+}
+
+// NewSync creates a new concurrency-safe digest. The options configure
+// the underlying digest like in New.
+func NewSync(options ...tdigestOption) (*SyncTDigest, error) {
+	digest, err := New(options...)
+	if err != nil {
+		return nil, err
+	}
+	return &SyncTDigest{digest: digest}, nil
+}
+
+// AddWeighted registers a new sample in the digest. Refer to
+// TDigest.AddWeighted for more details.
+func (s *SyncTDigest) AddWeighted(value float64, count uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.digest.AddWeighted(value, count)
+}
+
+// Add is an alias for AddWeighted(x,1)
+func (s *SyncTDigest) Add(value float64) error {
+	return s.AddWeighted(value, 1)
+}
+
+// Merge joins a given digest into itself. Refer to TDigest.Merge for
+// more details.
+//
+// Only this digest is locked: other must not be modified concurrently.
+func (s *SyncTDigest) Merge(other *TDigest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.digest.Merge(other)
+}
+
+// Takes the read lock, unless querying the digest may carry out a
+// deferred compression (see LazyCompress). Returns the unlock function.
+func (s *SyncTDigest) lockForQuery() (unlock func()) {
+	if s.digest.lazyCompress {
+		s.mu.Lock()
+		return s.mu.Unlock
+	}
+	s.mu.RLock()
+	return s.mu.RUnlock
+}
+
+// Merges this digest into dst, which must not be shared
+func (s *SyncTDigest) mergeInto(dst *TDigest) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return dst.Merge(s.digest)
+}
+
+// Compress tries to reduce the number of individual centroids stored
+// in the digest. Refer to TDigest.Compress for more details.
+func (s *SyncTDigest) Compress() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.digest.Compress()
+}
+
+// FromBytes deserializes into this digest, discarding any previously
+// collected data. Refer to TDigest.FromBytes for more details.
+func (s *SyncTDigest) FromBytes(buf []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.digest.FromBytes(buf)
+}
+
+// Quantile returns the desired percentile estimation. Refer to
+// TDigest.Quantile for more details.
+func (s *SyncTDigest) Quantile(q float64) float64 {
+	defer s.lockForQuery()()
+	return s.digest.Quantile(q)
+}
+
+// Quantiles returns the quantile estimations for each of the given
+// quantiles. Refer to TDigest.Quantiles for more details.
+func (s *SyncTDigest) Quantiles(qs []float64) []float64 {
+	defer s.lockForQuery()()
+	return s.digest.Quantiles(qs)
+}
+
+// CDF computes the fraction in which all samples are less than
+// or equal to the given value. Refer to TDigest.CDF for more details.
+func (s *SyncTDigest) CDF(value float64) float64 {
+	defer s.lockForQuery()()
+	return s.digest.CDF(value)
+}
+
+// Count returns the total number of samples this digest represents.
+func (s *SyncTDigest) Count() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.digest.Count()
+}
+
+// Compression returns the digest compression.
+func (s *SyncTDigest) Compression() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.digest.Compression()
+}
+
+// ForEachCentroid calls the specified function for each centroid.
+// Refer to TDigest.ForEachCentroid for more details.
+//
+// The read lock is held during the whole iteration, so the function
+// must not modify this digest or it will deadlock.
+func (s *SyncTDigest) ForEachCentroid(f func(mean float64, count uint64) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.digest.ForEachCentroid(f)
+}
+
+// AsBytes serializes the digest into a byte array. Refer to
+// TDigest.AsBytes for more details.
+func (s *SyncTDigest) AsBytes() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.digest.AsBytes()
+}
+
+// ToBytes serializes into the supplied slice. Refer to TDigest.ToBytes
+// for more details.
+func (s *SyncTDigest) ToBytes(b []byte) []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.digest.ToBytes(b)
+}
@@ -0,0 +1,108 @@
+package tdigest
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestSyncTDigestRace(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Eager", func(t *testing.T) {
+		testSyncTDigestRace(t, Compression(100))
+	})
+
+	// Queries compress, so they can't share the read lock
+	t.Run("Lazy", func(t *testing.T) {
+		testSyncTDigestRace(t, Compression(10), LazyCompress())
+	})
+}
+
+func testSyncTDigestRace(t *testing.T, options ...tdigestOption) {
+	digest, err := NewSync(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := uncheckedNew()
+	for i := 0; i < 1000; i++ {
+		_ = other.Add(float64(i))
+	}
+
+	const writers, readers, iterations = 4, 4, 1000
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < iterations; i++ {
+				_ = digest.Add(rng.Float64())
+				if i%250 == 0 {
+					_ = digest.Merge(other)
+					_ = digest.Compress()
+				}
+			}
+		}(int64(w))
+	}
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				digest.Quantile(0.5)
+				digest.Quantiles([]float64{0.1, 0.9})
+				digest.CDF(0.5)
+				digest.Count()
+				digest.ForEachCentroid(func(mean float64, count uint64) bool {
+					return true
+				})
+				_, _ = digest.AsBytes()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	expected := uint64(writers * (iterations + 4*1000))
+	if digest.Count() != expected {
+		t.Errorf("Expected count %d, got %d", expected, digest.Count())
+	}
+}
+
+func TestSyncTDigestSerialization(t *testing.T) {
+	digest, _ := NewSync()
+	for i := 0; i < 100; i++ {
+		_ = digest.Add(float64(i))
+	}
+
+	data, err := digest.AsBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(digest.ToBytes(nil)) != string(data) {
+		t.Errorf("Expected ToBytes and AsBytes to agree")
+	}
+
+	restored, _ := NewSync(Compression(10))
+	err = restored.FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Count() != digest.Count() || restored.Compression() != digest.Compression() {
+		t.Errorf("Deserialized to something different")
+	}
+
+	if restored.Quantile(0.5) != digest.Quantile(0.5) {
+		t.Errorf("Expected the same estimations after deserialization")
+	}
+
+	if _, err := NewSync(Compression(0)); err == nil {
+		t.Errorf("Expected error for invalid options")
+	}
+}
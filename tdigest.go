@@ -25,8 +25,13 @@
 package tdigest
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"math"
+	"sort"
+	"unsafe"
 )
 
 // TDigest is a quantile approximation data structure.
@@ -35,8 +40,42 @@ type TDigest struct {
 	compression float64
 	count       uint64
 	rng         RNG
+
+	// Bounds for the adaptive compression, disabled when zero
+	minCompression float64
+	maxCompression float64
+
+	// Hard limit on the number of centroids, disabled when zero
+	maxCentroids int
+	// Count when the limit last triggered a compression
+	compressedAt uint64
+
+	// Number of centroids that triggers a compression, 20 times the
+	// compression when zero
+	compressThreshold int
+
+	// Maximum size of a centroid at quantile q, as a fraction of the
+	// count. 4*q*(1-q)/compression when nil
+	scaleFunc func(q, compression float64) float64
+
+	// Serialization format the digest was decoded from
+	version int
+
+	// Serialized state saved by Snapshot
+	snapshot []byte
+
+	// Whether compressing is deferred to the next query (or explicit
+	// Compress call) and if there's one pending
+	lazyCompress  bool
+	needsCompress bool
+
+	frozen bool
 }
 
+// ErrFrozen is returned when trying to modify a digest after calling
+// Freeze.
+var ErrFrozen = errors.New("digest is frozen")
+
 // New creates a new digest.
 //
 // By default the digest is constructed with a configuration that
@@ -54,11 +93,26 @@ func New(options ...tdigestOption) (*TDigest, error) {
 	return tdigest, nil
 }
 
+// MustNew is like New but panics if the options are invalid.
+//
+// Since invalid options are programming errors rather than runtime
+// conditions, this simplifies initializing digests with fixed
+// options, e.g.: in package-level variables or struct literals.
+// Prefer New when the options come from user input or configuration.
+func MustNew(options ...tdigestOption) *TDigest {
+	t, err := New(options...)
+	if err != nil {
+		panic("tdigest: MustNew: " + err.Error())
+	}
+	return t
+}
+
 // Creates a tdigest instance without allocating a summary.
 func newWithoutSummary(options ...tdigestOption) (*TDigest, error) {
 	tdigest := &TDigest{
 		compression: 100,
 		count:       0,
+		version:     currentVersion,
 	}
 
 	for _, option := range options {
@@ -75,6 +129,113 @@ func newWithoutSummary(options ...tdigestOption) (*TDigest, error) {
 	return tdigest, nil
 }
 
+// NewFromSlice creates a digest with the given options holding all
+// the samples in data, which is left untouched.
+//
+// Repeated values are registered at once, with a single AddWeighted
+// call, and the samples are added in random order to avoid the poor
+// centroid distribution that sorted inputs lead to.
+//
+// This will emit an error if the options are invalid or if data
+// contains NaN or infinite values.
+func NewFromSlice(data []float64, options ...tdigestOption) (*TDigest, error) {
+	t, err := New(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := append([]float64{}, data...)
+	sort.Float64s(sorted)
+
+	runs := newSummary(len(sorted))
+	for i := 0; i < len(sorted); {
+		j := i + 1
+		for j < len(sorted) && sorted[j] == sorted[i] {
+			j++
+		}
+		runs.means = append(runs.means, sorted[i])
+		runs.counts = append(runs.counts, uint64(j-i))
+		i = j
+	}
+
+	runs.Perm(t.rng, func(value float64, count uint64) bool {
+		err = t.AddWeighted(value, count)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// NewFromMap creates a digest with the given options holding the
+// pre-aggregated samples in data, which maps each value to the number
+// of times it occurred (as in AddWeighted).
+//
+// Like with NewFromSlice, the entries are added in random order, but
+// since that order comes from the digest RNG rather than from the map
+// iteration, the result is reproducible.
+//
+// This will emit an error if the options are invalid, if data contains
+// NaN or infinite values or if any count is zero.
+func NewFromMap(data map[float64]uint64, options ...tdigestOption) (*TDigest, error) {
+	t, err := New(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := newSummary(len(data))
+	for value, count := range data {
+		entries.means = append(entries.means, value)
+		entries.counts = append(entries.counts, count)
+	}
+	sort.Sort(entries)
+
+	entries.Perm(t.rng, func(value float64, count uint64) bool {
+		err = t.AddWeighted(value, count)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Centroid is a (mean, count) pair, the building block of a digest.
+type Centroid struct {
+	Mean  float64 `json:"mean"`
+	Count uint64  `json:"count"`
+}
+
+// CentroidSnapshot is the type of the centroids returned by Centroids.
+type CentroidSnapshot = Centroid
+
+// NewFromCentroids creates a digest holding exactly the given centroids.
+//
+// Unlike adding each centroid via AddWeighted, the centroids are kept
+// as they are (only sorted by mean), so this can be used to restore a
+// digest from a known state, e.g.: the output of GoString. The given
+// compression and options configure the digest as in New.
+//
+// This will emit an error if any centroid has a NaN mean or a zero
+// count, or if the options are invalid.
+func NewFromCentroids(compression float64, centroids []Centroid, options ...tdigestOption) (*TDigest, error) {
+	t, err := newWithoutSummary(append([]tdigestOption{Compression(compression)}, options...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	t.summary = newSummary(len(centroids))
+	for _, c := range centroids {
+		err = t.summary.Add(c.Mean, c.Count)
+		if err != nil {
+			return nil, err
+		}
+		t.count += c.Count
+	}
+	return t, nil
+}
+
 func _quantile(index float64, previousIndex float64, nextIndex float64, previousMean float64, nextMean float64) float64 {
 	delta := nextIndex - previousIndex
 	previousWeight := (nextIndex - index) / delta
@@ -82,19 +243,87 @@ func _quantile(index float64, previousIndex float64, nextIndex float64, previous
 	return previousMean*previousWeight + nextMean*nextWeight
 }
 
+// Version returns the serialization format version of the digest: the
+// one it was decoded from (via FromBytes, ReadFrom, etc) or the current
+// one (2) for digests created otherwise. Serializing always uses the
+// current format, so it tells consumers how the centroids of decoded
+// digests were stored, e.g.: version 2 stores means as float32 deltas,
+// so they may have lost some precision.
+//
+// Returns 0 for zero-value digests that haven't been decoded into.
+func (t *TDigest) Version() int {
+	return t.version
+}
+
 // Compression returns the TDigest compression.
 func (t *TDigest) Compression() float64 {
 	return t.compression
 }
 
+// SetCompression changes the compression of an existing digest.
+//
+// When lowering the compression the digest is compressed right away so
+// that it holds no more centroids than the new value allows. Raising
+// it doesn't redistribute the existing centroids: precision improves
+// gradually as new samples are added. Setting the compression
+// explicitly disables the AdaptiveCompression option, if used.
+//
+// Compression must be a value greater or equal to 1, will yield an
+// error otherwise.
+func (t *TDigest) SetCompression(compression float64) error {
+	if t.frozen {
+		return ErrFrozen
+	}
+
+	if math.IsNaN(compression) || compression < 1 {
+		return fmt.Errorf("compression must be >= 1, got %f", compression)
+	}
+
+	previous := t.compression
+	t.compression = compression
+	t.minCompression = 0
+	t.maxCompression = 0
+
+	if compression < previous {
+		return t.Compress()
+	}
+	return nil
+}
+
+// Min returns the mean of the leftmost centroid, in constant time.
+//
+// Since the size of the centroids shrinks towards the tails this is
+// usually the smallest registered sample, but after merging or
+// compressing the extreme centroid may hold more than one sample.
+// Returns NaN for empty digests.
+func (t *TDigest) Min() float64 {
+	if t.summary.Len() == 0 {
+		return math.NaN()
+	}
+	return t.summary.Mean(0)
+}
+
+// Max returns the mean of the rightmost centroid, in constant time.
+//
+// Like for Min, this is usually the largest registered sample.
+// Returns NaN for empty digests.
+func (t *TDigest) Max() float64 {
+	if t.summary.Len() == 0 {
+		return math.NaN()
+	}
+	return t.summary.Mean(t.summary.Len() - 1)
+}
+
 // Quantile returns the desired percentile estimation.
 //
 // Values of p must be between 0 and 1 (inclusive), will panic otherwise.
+// Use Percentile instead if you prefer the 0 to 100 scale.
 func (t *TDigest) Quantile(q float64) float64 {
 	if q < 0 || q > 1 {
 		panic("q must be between 0 and 1 (inclusive)")
 	}
 
+	t.compressIfNeeded()
 	if t.summary.Len() == 0 {
 		return math.NaN()
 	} else if t.summary.Len() == 1 {
@@ -102,9 +331,78 @@ func (t *TDigest) Quantile(q float64) float64 {
 	}
 
 	index := q * float64(t.count-1)
+	next, total := t.summary.FloorSum(index)
+	return t.quantileAt(index, next, total)
+}
+
+// Quantiles returns the quantile estimations for each of the given
+// quantiles, in the same order.
+//
+// This is equivalent to calling Quantile for each of them, but the
+// centroids are walked through only once, which is noticeably faster
+// when computing several quantiles of a digest with many centroids.
+//
+// Values of q must be between 0 and 1 (inclusive), will panic otherwise.
+func (t *TDigest) Quantiles(qs []float64) []float64 {
+	for _, q := range qs {
+		if q < 0 || q > 1 {
+			panic("q must be between 0 and 1 (inclusive)")
+		}
+	}
+
+	t.compressIfNeeded()
+	result := make([]float64, len(qs))
+	if t.summary.Len() <= 1 {
+		for i, q := range qs {
+			result[i] = t.Quantile(q)
+		}
+		return result
+	}
+
+	order := make([]int, len(qs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return qs[order[i]] < qs[order[j]] })
+
+	// Like FloorSum, but resuming from where the previous (smaller)
+	// quantile left off
+	next, total := 0, float64(0)
+	for _, i := range order {
+		index := qs[i] * float64(t.count-1)
+		for next+1 < t.summary.Len() && total+float64(t.summary.Count(next)) <= index {
+			total += float64(t.summary.Count(next))
+			next++
+		}
+		result[i] = t.quantileAt(index, next, total)
+	}
+	return result
+}
+
+// Report returns the quantile estimations for each of the given
+// quantiles keyed by the quantile itself, which is convenient for
+// logging, e.g.: {0.5: 12.3, 0.99: 45.6}.
+//
+// An empty digest yields an empty (non-nil) map. Values of q must be
+// between 0 and 1 (inclusive), will panic otherwise.
+func (t *TDigest) Report(quantiles []float64) map[float64]float64 {
+	values := t.Quantiles(quantiles)
+	report := make(map[float64]float64, len(quantiles))
+	if t.summary.Len() == 0 {
+		return report
+	}
+	for i, q := range quantiles {
+		report[q] = values[i]
+	}
+	return report
+}
+
+// quantileAt estimates the value at the given index (in [0, count-1]),
+// where next is the last centroid that starts at or before the index
+// and total is the number of samples before it.
+func (t *TDigest) quantileAt(index float64, next int, total float64) float64 {
 	previousMean := math.NaN()
 	previousIndex := float64(0)
-	next, total := t.summary.FloorSum(index)
 
 	if next > 0 {
 		previousMean = t.summary.Mean(next - 1)
@@ -139,6 +437,125 @@ func (t *TDigest) Quantile(q float64) float64 {
 	// unreachable
 }
 
+// QuickQuantile returns a rough quantile estimation in constant time.
+//
+// NOTE: The estimation is MUCH less accurate than the one provided by
+// Quantile: only the first and last 3 centroids are looked at and
+// everything in between is assumed to grow linearly. So it's only
+// decent for (nearly) uniform data: e.g.: for 100000 exponentially
+// distributed samples the estimation for q=0.99 is off by more than
+// the actual value (~4.6). Only use it in hot paths where even walking
+// through the centroids is too slow.
+//
+// Digests with 10 or less centroids use Quantile instead.
+//
+// Values of q must be between 0 and 1 (inclusive), will panic otherwise.
+func (t *TDigest) QuickQuantile(q float64) float64 {
+	if q < 0 || q > 1 {
+		panic("q must be between 0 and 1 (inclusive)")
+	}
+
+	n := t.summary.Len()
+	if n <= 10 {
+		return t.Quantile(q)
+	}
+
+	const edge = 3
+
+	// (index, mean) for the center of each of the boundary centroids
+	var indexes, means [2 * edge]float64
+	head, tail := float64(0), float64(t.count)
+	for i := 0; i < edge; i++ {
+		count := float64(t.summary.Count(i))
+		indexes[i] = head + (count-1)/2
+		means[i] = t.summary.Mean(i)
+		head += count
+
+		j := n - 1 - i
+		count = float64(t.summary.Count(j))
+		tail -= count
+		indexes[2*edge-1-i] = tail + (count-1)/2
+		means[2*edge-1-i] = t.summary.Mean(j)
+	}
+
+	index := q * float64(t.count-1)
+	if index <= indexes[0] {
+		return means[0]
+	}
+	for i := 1; i < len(indexes); i++ {
+		if index <= indexes[i] {
+			return _quantile(index, indexes[i-1], indexes[i], means[i-1], means[i])
+		}
+	}
+	return means[len(means)-1]
+}
+
+// Percentiles returns the quantile estimations for each of the
+// given percentiles, in the same order.
+//
+// Unlike Quantile, percentiles are expressed as percentages, so
+// Percentiles(50, 99) is equivalent to calling Quantile(0.5) and
+// Quantile(0.99). Values of p must be between 0 and 100 (inclusive),
+// will panic otherwise.
+func (t *TDigest) Percentiles(ps ...float64) []float64 {
+	for _, p := range ps {
+		if p < 0 || p > 100 {
+			panic("p must be between 0 and 100 (inclusive)")
+		}
+	}
+
+	qs := make([]float64, len(ps))
+	for i, p := range ps {
+		qs[i] = p / 100
+	}
+	return t.Quantiles(qs)
+}
+
+// Percentile returns the estimation for the given percentile, i.e.:
+// Percentile(99) is equivalent to Quantile(0.99).
+//
+// Values of p must be between 0 and 100 (inclusive), will panic
+// otherwise. Returns NaN for empty digests.
+func (t *TDigest) Percentile(p float64) float64 {
+	if p < 0 || p > 100 {
+		panic("p must be between 0 and 100 (inclusive)")
+	}
+	return t.Quantile(p / 100)
+}
+
+// PercentileMany is the batch variant of Percentile, equivalent to
+// Percentiles(ps...).
+func (t *TDigest) PercentileMany(ps []float64) []float64 {
+	return t.Percentiles(ps...)
+}
+
+// IQR returns the interquartile range, i.e.: the difference between
+// the estimations of the 0.75 and 0.25 quantiles, computed in a single
+// pass. Returns NaN for empty digests.
+func (t *TDigest) IQR() float64 {
+	quartiles := t.Quantiles([]float64{0.25, 0.75})
+	return quartiles[1] - quartiles[0]
+}
+
+// QuantileError returns a conservative bound for the absolute error
+// of the estimation of the quantile q with respect to the exact
+// quantile of the samples registered in the digest.
+//
+// The bound, q * (1 - q) / compression, follows from the size limit
+// imposed on the centroids: it's tighter on the tails, which is where
+// the t-digest is most accurate. E.g.: with the default compression
+// of 100, the error bound for q=0.99 is 0.000099. Notice that the
+// bound is expressed in quantile space, so it's also the absolute
+// error in value space for data uniformly distributed in [0, 1).
+//
+// Values of q must be between 0 and 1 (inclusive), will panic otherwise.
+func (t *TDigest) QuantileError(q float64) float64 {
+	if q < 0 || q > 1 {
+		panic("q must be between 0 and 1 (inclusive)")
+	}
+	return q * (1 - q) / t.compression
+}
+
 // boundedWeightedAverage computes the weighted average of two
 // centroids guaranteeing that the result will be between x1 and x2,
 // inclusive.
@@ -159,11 +576,66 @@ func boundedWeightedAverage(x1 float64, w1 float64, x2 float64, w2 float64) floa
 // when you are registering a sample that occurred multiple times - the
 // most common value for this is 1.
 //
-// This will emit an error if `value` is NaN or if `count` is zero.
+// This will emit an error if `value` is NaN or infinite or if `count`
+// is zero. Infinities are rejected since they would corrupt the means
+// of the centroids they get merged into, and the serialization (which
+// stores the means as float32 deltas) can't represent them faithfully.
 func (t *TDigest) AddWeighted(value float64, count uint64) (err error) {
-	if count == 0 {
+	if t.frozen {
+		return ErrFrozen
+	}
+
+	err = t.add(value, count)
+	if err == nil && t.shouldCompress() {
+		if t.lazyCompress {
+			t.needsCompress = true
+		} else {
+			err = t.Compress()
+		}
+	}
+	if err == nil && t.maxCompression > 0 {
+		err = t.adaptCompression()
+	}
+	if err == nil && t.maxCentroids > 0 && t.summary.Len() > t.maxCentroids {
+		err = t.enforceMaxCentroids()
+	}
+	return err
+}
+
+// Brings the number of centroids down to the MaxCentroids limit by
+// merging the nearest centroids. When the limit is lower than what the
+// compression allows, compressing barely helps (new samples don't fit
+// the oversized centroids), so it's only attempted once the count has
+// doubled since the last time.
+func (t *TDigest) enforceMaxCentroids() error {
+	if t.count >= 2*t.compressedAt {
+		t.compressedAt = t.count
+		err := t.Compress()
+		if err != nil {
+			return err
+		}
+	}
+	for t.summary.Len() > t.maxCentroids {
+		t.summary.mergeNearest()
+	}
+	return nil
+}
+
+// Checks whether the sample can be added to a digest.
+func validSample(value float64, count uint64) error {
+	if count == 0 || math.IsNaN(value) || math.IsInf(value, 0) {
 		return fmt.Errorf("illegal datapoint <value: %.4f, count: %d>", value, count)
 	}
+	return nil
+}
+
+// add does the heavy lifting for AddWeighted, but doesn't take the
+// adaptive compression into account, so that it's safe to use while
+// compressing.
+func (t *TDigest) add(value float64, count uint64) (err error) {
+	if err := validSample(value, count); err != nil {
+		return err
+	}
 
 	if t.summary.Len() == 0 {
 		err = t.summary.Add(value, count)
@@ -191,12 +663,44 @@ func (t *TDigest) AddWeighted(value float64, count uint64) (err error) {
 		t.summary.setAt(closest, newMean, uint64(c)+count)
 	}
 	t.count += uint64(count)
+	return err
+}
+
+// Whether there are enough centroids to warrant a compression
+func (t *TDigest) shouldCompress() bool {
+	return float64(t.summary.Len()) > t.compressionThreshold()
+}
 
-	if float64(t.summary.Len()) > 20*t.compression {
-		err = t.Compress()
+// The number of centroids above which the digest gets compressed.
+func (t *TDigest) compressionThreshold() float64 {
+	if t.compressThreshold > 0 {
+		return float64(t.compressThreshold)
 	}
+	return 20 * t.compression
+}
 
-	return err
+// Performs the compression deferred by the LazyCompress option, if
+// any. Can't fail: the centroids are known to be valid and frozen
+// digests have nothing pending.
+func (t *TDigest) compressIfNeeded() {
+	if t.needsCompress {
+		_ = t.Compress()
+	}
+}
+
+// Grows the compression logarithmically with the number of samples,
+// within the bounds set by the AdaptiveCompression option.
+func (t *TDigest) adaptCompression() error {
+	compression := math.Floor(math.Log10(float64(t.count))) * adaptiveCompressionScale
+	compression = math.Max(t.minCompression, math.Min(compression, t.maxCompression))
+
+	if compression <= t.compression {
+		return nil
+	}
+
+	// Compressing with the new value lets new centroids form
+	t.compression = compression
+	return t.Compress()
 }
 
 // Count returns the total number of samples this digest represents
@@ -225,6 +729,21 @@ func (t *TDigest) Add(value float64) error {
 	return t.AddWeighted(value, 1)
 }
 
+// AddAll registers all the given samples in the digest, in order.
+//
+// It stops at the first sample that can't be added (see AddWeighted),
+// returning an error that tells its index. Since samples are added in
+// order, the index is also the number of samples that were added.
+func (t *TDigest) AddAll(values ...float64) error {
+	for i, value := range values {
+		err := t.AddWeighted(value, 1)
+		if err != nil {
+			return fmt.Errorf("failed to add sample %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
 // Compress tries to reduce the number of individual centroids stored
 // in the digest.
 //
@@ -237,6 +756,11 @@ func (t *TDigest) Add(value float64) error {
 // after it grows too much. If you are minimizing network traffic
 // it might be a good idea to compress before serializing.
 func (t *TDigest) Compress() (err error) {
+	if t.frozen {
+		return ErrFrozen
+	}
+
+	t.needsCompress = false
 	if t.summary.Len() <= 1 {
 		return nil
 	}
@@ -247,12 +771,92 @@ func (t *TDigest) Compress() (err error) {
 
 	oldTree.shuffle(t.rng)
 	oldTree.ForEach(func(mean float64, count uint64) bool {
-		err = t.AddWeighted(mean, count)
+		err = t.add(mean, count)
 		return err == nil
 	})
 	return err
 }
 
+// CompressTo compresses the digest until it holds at most
+// targetCentroids centroids.
+//
+// This works by repeatedly calling Compress with a progressively
+// lower (temporary) compression value until the target is reached
+// or until compressing further stops reducing the number of
+// centroids, so the result may still be above the target. The
+// configured compression is restored afterwards. Useful when the
+// serialized digest must fit a fixed size budget.
+//
+// Values of targetCentroids must be greater or equal to 1, will
+// yield an error otherwise.
+func (t *TDigest) CompressTo(targetCentroids int) error {
+	if targetCentroids < 1 {
+		return fmt.Errorf("targetCentroids must be >= 1, got %d", targetCentroids)
+	}
+
+	compression := t.compression
+	defer func() {
+		t.compression = compression
+	}()
+
+	for t.summary.Len() > targetCentroids {
+		before := t.summary.Len()
+
+		err := t.Compress()
+		if err != nil {
+			return err
+		}
+
+		if t.compression == 1 && t.summary.Len() >= before {
+			break
+		}
+		t.compression = math.Max(1, t.compression/2)
+	}
+	return nil
+}
+
+// Compact returns a copy of the digest holding the same centroids,
+// with the compression set to the value that yields about as many
+// centroids as it currently holds (10 per unit of compression, at
+// least 1). The digest itself is left untouched.
+//
+// Unlike Compress, which keeps the compression, this adjusts the
+// compression to the data: e.g.: after merging many small digests, a
+// digest configured with a compression of 1000 may hold just a few
+// centroids, so its serialization and its growth budget (see
+// CompressAfter) are oversized for what it holds. Since the centroids
+// are copied as they are, the copy gives the exact same quantile
+// estimations. The copy doesn't use AdaptiveCompression, like after
+// SetCompression, and it's mutable even if the digest is frozen.
+// Empty digests keep their compression.
+func (t *TDigest) Compact() *TDigest {
+	compact := t.slice(0, t.summary.Len())
+	if t.summary.Len() > 0 {
+		compact.compression = math.Max(1, math.Round(float64(t.summary.Len())/centroidsPerCompression))
+		compact.minCompression = 0
+		compact.maxCompression = 0
+	}
+	return compact
+}
+
+// Reserve pre-allocates room for at least n centroids.
+//
+// This is analogous to growing the capacity of a slice: if the
+// digest can already hold n centroids without reallocating nothing
+// happens, otherwise the internal storage is grown and the existing
+// data is copied over. Notice that compressing the digest (which
+// may happen automatically) resets the storage to its default size.
+//
+// Values of n must be greater than zero, will yield an error
+// otherwise.
+func (t *TDigest) Reserve(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("n must be > 0, got %d", n)
+	}
+	t.summary.Reserve(n)
+	return nil
+}
+
 // Merge joins a given digest into itself.
 //
 // Merging is useful when you have multiple TDigest instances running
@@ -260,10 +864,59 @@ func (t *TDigest) Compress() (err error) {
 // samples. This is particularly important on a scatter-gather/map-reduce
 // scenario.
 func (t *TDigest) Merge(other *TDigest) (err error) {
+	return t.MergeWithRNG(other, t.rng)
+}
+
+// MergeMany joins all the given digests into itself.
+//
+// It's equivalent to calling Merge for each of them, but the centroids
+// of all digests are shuffled together and added in a single pass, so
+// the additions are interleaved instead of fed one digest at a time.
+// Notice that the cost of merging is still dominated by adding every
+// centroid, so don't expect it to be much faster than a Merge loop.
+func (t *TDigest) MergeMany(others []*TDigest) (err error) {
+	size := 0
+	for _, other := range others {
+		size += other.summary.Len()
+	}
+
+	if size == 0 {
+		return nil
+	}
+
+	all := newSummary(size)
+	for _, other := range others {
+		all.means = append(all.means, other.summary.means...)
+		all.counts = append(all.counts, other.summary.counts...)
+	}
+
+	all.shuffle(t.rng)
+	all.ForEach(func(mean float64, count uint64) bool {
+		err = t.AddWeighted(mean, count)
+		return err == nil
+	})
+	return err
+}
+
+// MergeWithRNG works like Merge, but every random decision taken while
+// merging (shuffling the centroids of 'other' as well as choosing
+// between equally good merge candidates) uses the given rng instead of
+// the one this digest was configured with.
+//
+// Since the configured RNG isn't touched, merging the same digests
+// with identically seeded RNGs always yields the same result. This is
+// mostly useful for writing deterministic tests.
+func (t *TDigest) MergeWithRNG(other *TDigest, rng RNG) (err error) {
 	if other.summary.Len() == 0 {
 		return nil
 	}
 
+	configured := t.rng
+	t.rng = rng
+	defer func() {
+		t.rng = configured
+	}()
+
 	other.summary.Perm(t.rng, func(mean float64, count uint64) bool {
 		err = t.AddWeighted(mean, count)
 		return err == nil
@@ -278,6 +931,10 @@ func (t *TDigest) Merge(other *TDigest) (err error) {
 // requires caution as it makes 'other' useless - you must make
 // sure you discard it without making further uses of it.
 func (t *TDigest) MergeDestructive(other *TDigest) (err error) {
+	if t.frozen || other.frozen {
+		return ErrFrozen
+	}
+
 	if other.summary.Len() == 0 {
 		return nil
 	}
@@ -290,56 +947,759 @@ func (t *TDigest) MergeDestructive(other *TDigest) (err error) {
 	return err
 }
 
-// CDF computes the fraction in which all samples are less than
-// or equal to the given value.
-func (t *TDigest) CDF(value float64) float64 {
-	if t.summary.Len() == 0 {
-		return math.NaN()
-	} else if t.summary.Len() == 1 {
-		if value < t.summary.Mean(0) {
-			return 0
-		}
-		return 1
+// PartialMerge joins a fraction of a given digest into itself.
+//
+// It works like Merge, but the count of every centroid in 'other'
+// is scaled by `weight` (and rounded to the nearest integer) before
+// being added. This is useful for weighted federation, e.g.: when
+// combining a digest that represents 30% of the traffic with one
+// that represents the other 70%. Centroids whose scaled count rounds
+// to zero are skipped, so the count of this digest grows by roughly
+// (but not exactly) weight * other.Count().
+//
+// Values of weight must be in (0, 1], will yield an error otherwise.
+func (t *TDigest) PartialMerge(other *TDigest, weight float64) (err error) {
+	if math.IsNaN(weight) || weight <= 0 || weight > 1 {
+		return fmt.Errorf("weight must be in (0, 1], got %f", weight)
 	}
 
-	// We have at least 2 centroids
-	left := (t.summary.Mean(1) - t.summary.Mean(0)) / 2
-	right := left
-	tot := 0.0
+	if other.summary.Len() == 0 {
+		return nil
+	}
 
-	for i := 1; i < t.summary.Len()-1; i++ {
-		prevMean := t.summary.Mean(i - 1)
-		if value < prevMean+right {
-			v := (tot + float64(t.summary.Count(i-1))*interpolate(value, prevMean-left, prevMean+right)) / float64(t.Count())
+	other.summary.Perm(t.rng, func(mean float64, count uint64) bool {
+		scaled := uint64(math.Round(weight * float64(count)))
+		if scaled == 0 {
+			return true
+		}
+		err = t.AddWeighted(mean, scaled)
+		return err == nil
+	})
+	return err
+}
+
+// FlushTo drains this digest into dst, leaving it empty.
+//
+// This packages the common pattern of periodically flushing a
+// local accumulator into a global digest. Since this digest is emptied
+// afterwards, its centroids are moved via MergeDestructive. This
+// digest is only emptied when the merge succeeds: if it fails this
+// digest keeps all of its centroids, but dst may have already
+// received part of them.
+//
+// This will emit an error if dst is this digest or if either digest
+// is frozen.
+func (t *TDigest) FlushTo(dst *TDigest) error {
+	if t.frozen {
+		return ErrFrozen
+	}
+	if dst == t {
+		return errors.New("can't flush a digest into itself")
+	}
+
+	err := dst.MergeDestructive(t)
+	if err != nil {
+		// MergeDestructive shuffles the centroids
+		sort.Sort(t.summary)
+		return err
+	}
+	t.Reset()
+	return nil
+}
+
+// CDF computes the fraction in which all samples are less than
+// or equal to the given value.
+func (t *TDigest) CDF(value float64) float64 {
+	t.compressIfNeeded()
+	if t.summary.Len() == 0 {
+		return math.NaN()
+	} else if t.summary.Len() == 1 {
+		if value < t.summary.Mean(0) {
+			return 0
+		}
+		return 1
+	}
+
+	// We have at least 2 centroids
+	cursor := t.newCDFCursor()
+	return t.cdfAt(value, &cursor)
+}
+
+// Rank returns the estimated number of samples less than or equal to
+// the given value, i.e.: CDF(value) * Count(), truncated to an
+// integer. It's the inverse of Quantile in terms of sample counts.
+//
+// Returns 0 for empty digests.
+func (t *TDigest) Rank(value float64) uint64 {
+	if t.count == 0 {
+		return 0
+	}
+	return uint64(t.CDF(value) * float64(t.count))
+}
+
+// CDFs returns the CDF for each of the given values, in the same
+// order.
+//
+// This is equivalent to calling CDF for each of them, but the
+// centroids are walked through only once, which is noticeably faster
+// when evaluating many values (e.g.: the buckets of a histogram or a
+// heatmap).
+func (t *TDigest) CDFs(values []float64) []float64 {
+	t.compressIfNeeded()
+	result := make([]float64, len(values))
+	if t.summary.Len() <= 1 {
+		for i, value := range values {
+			result[i] = t.CDF(value)
+		}
+		return result
+	}
+
+	order := make([]int, 0, len(values))
+	for i, value := range values {
+		if math.IsNaN(value) {
+			// Can't be sorted, so it would derail the walk
+			result[i] = t.CDF(value)
+			continue
+		}
+		order = append(order, i)
+	}
+	sort.Slice(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+
+	cursor := t.newCDFCursor()
+	for _, i := range order {
+		result[i] = t.cdfAt(values[i], &cursor)
+	}
+	return result
+}
+
+// Histogram returns the estimated cumulative count of samples less
+// than or equal to each of the given bucket upper bounds, like in a
+// Prometheus histogram, followed by the count of the implicit +Inf
+// bucket: the total number of samples. Counts are estimated as
+// CDF(bound) * Count(), rounded to the nearest integer.
+//
+// The buckets must not be empty, must be sorted in ascending order and
+// must not contain NaN, will panic otherwise.
+func (t *TDigest) Histogram(buckets []float64) []uint64 {
+	if len(buckets) == 0 {
+		panic("buckets must not be empty")
+	}
+	for _, bound := range buckets {
+		if math.IsNaN(bound) {
+			panic("buckets must not contain NaN")
+		}
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic("buckets must be sorted in ascending order")
+	}
+
+	result := make([]uint64, len(buckets)+1)
+	result[len(buckets)] = t.count
+	if t.count == 0 {
+		return result
+	}
+
+	for i, cdf := range t.CDFs(buckets) {
+		result[i] = uint64(math.Round(cdf * float64(t.count)))
+	}
+	return result
+}
+
+// PDF approximates the probability density of the samples: the range
+// between Min() and Max() is split in numBins intervals of equal width
+// and the density of each is estimated as its CDF difference divided
+// by its width. It returns the centers of the bins and their densities,
+// which is handy for plotting the shape of a distribution.
+//
+// Returns nil slices for empty digests. If all samples share the same
+// value a single bin is returned, at that value, with +Inf density.
+//
+// Values of numBins must be >= 1, will panic otherwise.
+func (t *TDigest) PDF(numBins int) (centers []float64, densities []float64) {
+	if numBins < 1 {
+		panic("numBins must be >= 1")
+	}
+
+	if t.summary.Len() == 0 {
+		return nil, nil
+	}
+
+	min, max := t.Min(), t.Max()
+	if min == max {
+		return []float64{min}, []float64{math.Inf(1)}
+	}
+
+	width := (max - min) / float64(numBins)
+	edges := make([]float64, numBins+1)
+	for i := range edges {
+		edges[i] = min + float64(i)*width
+	}
+	edges[numBins] = max
+
+	cdfs := t.CDFs(edges)
+	centers = make([]float64, numBins)
+	densities = make([]float64, numBins)
+	for i := range centers {
+		centers[i] = edges[i] + width/2
+		densities[i] = (cdfs[i+1] - cdfs[i]) / width
+	}
+	return centers, densities
+}
+
+// cdfCursor holds the progress of a walk through the centroids when
+// computing the CDF, so that it can be resumed for larger values.
+type cdfCursor struct {
+	i           int
+	left, right float64
+	tot         float64
+}
+
+// newCDFCursor returns a cursor at the start of the summary, which
+// must hold at least 2 centroids.
+func (t *TDigest) newCDFCursor() cdfCursor {
+	left := (t.summary.Mean(1) - t.summary.Mean(0)) / 2
+	return cdfCursor{i: 1, left: left, right: left}
+}
+
+// cdfAt computes the CDF of the given value, advancing the cursor.
+// Values must be given in ascending order when reusing a cursor.
+func (t *TDigest) cdfAt(value float64, c *cdfCursor) float64 {
+	for ; c.i < t.summary.Len()-1; c.i++ {
+		prevMean := t.summary.Mean(c.i - 1)
+		if value < prevMean+c.right {
+			v := (c.tot + float64(t.summary.Count(c.i-1))*interpolate(value, prevMean-c.left, prevMean+c.right)) / float64(t.Count())
 			if v > 0 {
 				return v
 			}
 			return 0
 		}
 
-		tot += float64(t.summary.Count(i - 1))
-		left = right
-		right = (t.summary.Mean(i+1) - t.summary.Mean(i)) / 2
+		c.tot += float64(t.summary.Count(c.i - 1))
+		c.left = c.right
+		c.right = (t.summary.Mean(c.i+1) - t.summary.Mean(c.i)) / 2
 	}
 
 	// last centroid, the summary length is at least two
 	aIdx := t.summary.Len() - 2
 	aMean := t.summary.Mean(aIdx)
-	if value < aMean+right {
+	if value < aMean+c.right {
 		aCount := float64(t.summary.Count(aIdx))
-		return (tot + aCount*interpolate(value, aMean-left, aMean+right)) / float64(t.Count())
+		return (c.tot + aCount*interpolate(value, aMean-c.left, aMean+c.right)) / float64(t.Count())
 	}
 	return 1
 }
 
+// CumulativeDensity computes the fraction in which all samples are
+// strictly less than the given value.
+//
+// This only differs from CDF when the value matches the mean of a
+// centroid exactly: half of the weight of the centroids at the value
+// is subtracted from CDF, which never makes the result greater than
+// CDF(value). When every centroid is at the value, the digest is taken
+// as a single point instead (like CDF does), so the result is 0. The
+// difference matters for discrete data, where many observations fall
+// exactly on centroid means.
+func (t *TDigest) CumulativeDensity(value float64) float64 {
+	if t.summary.Len() == 0 {
+		return math.NaN()
+	}
+
+	cdf := t.CDF(value)
+	idx := t.summary.findIndex(value)
+	end := idx
+	for end < t.summary.Len() && t.summary.Mean(end) == value {
+		end++
+	}
+	if end == idx {
+		return cdf
+	} else if idx == 0 && end == t.summary.Len() {
+		return 0
+	}
+
+	mass := (t.summary.HeadSum(end) - t.summary.HeadSum(idx)) / float64(t.count)
+	return math.Max(0, cdf-mass/2)
+}
+
+// Sample generates n synthetic samples following the distribution
+// approximated by the digest.
+//
+// Each sample is the quantile estimation of a uniformly distributed
+// random number drawn from the given rng, so sampling is reproducible
+// when rng is. This allows using the digest as a distribution for
+// Monte Carlo simulations or synthetic load generation. The estimations
+// are computed at once via Quantiles, in a single pass over the
+// centroids, while the samples are kept in the order they were drawn.
+//
+// This will emit an error if the digest is empty or if n <= 0.
+func (t *TDigest) Sample(n int, rng RNG) ([]float64, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("n must be > 0, got %d", n)
+	}
+	if t.summary.Len() == 0 {
+		return nil, fmt.Errorf("can't sample from an empty digest")
+	}
+
+	qs := make([]float64, n)
+	for i := range qs {
+		qs[i] = float64(rng.Float32())
+	}
+	return t.Quantiles(qs), nil
+}
+
+// The number of sub-digests built by BootstrapQuantile
+const bootstrapBatches = 10
+
+// BootstrapQuantile estimates the given quantile along with its
+// standard error, which can be used to build confidence intervals,
+// e.g.: estimate ± 1.96*stderr covers the true quantile roughly 95% of
+// the time.
+//
+// It draws n synthetic samples from the digest (see Sample) and splits
+// them into 10 sub-digests of n/10 samples each. The estimate is the
+// mean of their quantiles and the standard error is derived from their
+// spread, so it reflects the uncertainty of a quantile computed from n
+// samples: use n = Count() to assess the data the digest was built
+// from. The error of the digest itself is not accounted for.
+//
+// A fixed number of batches keeps the cost linear in n: resampling all
+// n samples for each of n/10 sub-digests would take O(n²) additions,
+// while sub-digests of 10 samples can't estimate the tails.
+//
+// Returns NaN for both values if the digest is empty or if n < 20.
+// Values of q must be between 0 and 1 (inclusive), will panic
+// otherwise.
+func (t *TDigest) BootstrapQuantile(q float64, n int, rng RNG) (estimate, stderr float64) {
+	if q < 0 || q > 1 {
+		panic("q must be between 0 and 1 (inclusive)")
+	}
+	if t.summary.Len() == 0 || n < 2*bootstrapBatches {
+		return math.NaN(), math.NaN()
+	}
+
+	samples, err := t.Sample(n, rng)
+	if err != nil {
+		return math.NaN(), math.NaN()
+	}
+
+	size := n / bootstrapBatches
+	estimates := make([]float64, bootstrapBatches)
+	for i := range estimates {
+		batch, err := New(Compression(t.compression))
+		if err != nil {
+			return math.NaN(), math.NaN()
+		}
+		err = batch.AddAll(samples[i*size : (i+1)*size]...)
+		if err != nil {
+			return math.NaN(), math.NaN()
+		}
+		estimates[i] = batch.Quantile(q)
+		estimate += estimates[i]
+	}
+	estimate /= bootstrapBatches
+
+	var variance float64
+	for _, e := range estimates {
+		variance += (e - estimate) * (e - estimate)
+	}
+	variance /= bootstrapBatches - 1
+	return estimate, math.Sqrt(variance / bootstrapBatches)
+}
+
+// Interpolate creates a new digest whose distribution lies between
+// the distributions of this digest and the other one.
+//
+// The quantiles of the resulting digest approximate
+//
+//	t.Quantile(q)*(1-alpha) + other.Quantile(q)*alpha
+//
+// for every q, so alpha=0 yields (an approximation of) this digest
+// and alpha=1 an approximation of the other. The result is built from
+// n evenly spaced quantiles, so larger values of n give more precise
+// results. This is useful for smooth transitions between two observed
+// distributions, such as during a gradual traffic migration.
+//
+// This will emit an error if either digest is empty, if alpha is not
+// between 0 and 1 (inclusive) or if n <= 0.
+func (t *TDigest) Interpolate(alpha float64, other *TDigest, n int) (*TDigest, error) {
+	if math.IsNaN(alpha) || alpha < 0 || alpha > 1 {
+		return nil, fmt.Errorf("alpha must be between 0 and 1 (inclusive), got %f", alpha)
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("n must be > 0, got %d", n)
+	}
+	if t.summary.Len() == 0 || other.summary.Len() == 0 {
+		return nil, fmt.Errorf("can't interpolate empty digests")
+	}
+
+	result, err := New(Compression(t.compression))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range perm(result.rng, n) {
+		q := (float64(i) + 0.5) / float64(n)
+		err = result.Add(t.Quantile(q)*(1-alpha) + other.Quantile(q)*alpha)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Normalize creates a new digest with the values of this digest
+// linearly scaled to the [0, 1] range.
+//
+// Each centroid mean x is replaced by (x - min) / (max - min), where
+// min and max are the lowest and highest means in the digest, while
+// counts are preserved. Since quantile estimations interpolate linearly
+// between centroids, the quantiles of the result are the normalized
+// quantiles of this digest. This is useful for comparing the shape of
+// distributions with different scales.
+//
+// This will emit an error if the digest is empty or if all of its
+// centroids share the same mean.
+func (t *TDigest) Normalize() (*TDigest, error) {
+	if t.summary.Len() == 0 {
+		return nil, fmt.Errorf("can't normalize an empty digest")
+	}
+
+	min, max := t.Min(), t.Max()
+	if min == max {
+		return nil, fmt.Errorf("can't normalize a digest with a zero value range")
+	}
+
+	result := t.Clone()
+	for i, mean := range result.summary.means {
+		result.summary.means[i] = (mean - min) / (max - min)
+	}
+	return result, nil
+}
+
+// Scale multiplies all the samples in the digest by factor, in place.
+//
+// This is useful for converting units, e.g.: from nanoseconds to
+// milliseconds, without rebuilding the digest. Negative factors are
+// allowed and mirror the distribution.
+//
+// This will emit an error if factor is zero, NaN or infinite, or if
+// scaling would overflow. The digest is left untouched on errors.
+func (t *TDigest) Scale(factor float64) error {
+	if t.frozen {
+		return ErrFrozen
+	}
+
+	if factor == 0 || math.IsNaN(factor) || math.IsInf(factor, 0) {
+		return fmt.Errorf("factor must be finite and non-zero, got %f", factor)
+	}
+
+	if t.summary.Len() == 0 {
+		return nil
+	}
+
+	if math.IsInf(t.Min()*factor, 0) || math.IsInf(t.Max()*factor, 0) {
+		return fmt.Errorf("scaling by %g overflows", factor)
+	}
+
+	for i := range t.summary.means {
+		t.summary.means[i] *= factor
+	}
+
+	if factor < 0 {
+		// The order is exactly reversed
+		for i, j := 0, t.summary.Len()-1; i < j; i, j = i+1, j-1 {
+			t.summary.Swap(i, j)
+		}
+	}
+	return nil
+}
+
+// Shift adds delta to all the samples in the digest, in place.
+//
+// This will emit an error if delta is NaN or infinite, or if shifting
+// would overflow. The digest is left untouched on errors.
+func (t *TDigest) Shift(delta float64) error {
+	if t.frozen {
+		return ErrFrozen
+	}
+
+	if math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return fmt.Errorf("delta must be finite, got %f", delta)
+	}
+
+	if t.summary.Len() == 0 {
+		return nil
+	}
+
+	if math.IsInf(t.Min()+delta, 0) || math.IsInf(t.Max()+delta, 0) {
+		return fmt.Errorf("shifting by %g overflows", delta)
+	}
+
+	// Every mean moves by the same amount, so the order is kept
+	for i := range t.summary.means {
+		t.summary.means[i] += delta
+	}
+	return nil
+}
+
+// Partition splits the digest at pivot: lo gets the centroids with
+// means below pivot and hi the ones with means greater than or equal
+// to it. Both share the configuration of this digest, which is left
+// untouched.
+//
+// Centroids are moved as they are, so lo.Count() + hi.Count() always
+// equals t.Count(). Notice that a centroid may summarize samples from
+// both sides of the pivot: the split is only as precise as the digest.
+//
+// This will emit an error if pivot is NaN.
+func (t *TDigest) Partition(pivot float64) (lo, hi *TDigest, err error) {
+	if math.IsNaN(pivot) {
+		return nil, nil, fmt.Errorf("pivot must not be NaN")
+	}
+
+	idx := t.summary.findIndex(pivot)
+	return t.slice(0, idx), t.slice(idx, t.summary.Len()), nil
+}
+
+// Returns a new digest with the same configuration, holding a copy of
+// the centroids within [from, to)
+func (t *TDigest) slice(from, to int) *TDigest {
+	counts := append([]uint64{}, t.summary.counts[from:to]...)
+	return &TDigest{
+		summary: &summary{
+			means:  append([]float64{}, t.summary.means[from:to]...),
+			counts: counts,
+		},
+		compression:    t.compression,
+		count:          sumUntilIndex(counts, len(counts)),
+		rng:            cloneRNG(t.rng),
+		minCompression: t.minCompression,
+		maxCompression: t.maxCompression,
+		maxCentroids:   t.maxCentroids,
+
+		compressThreshold: t.compressThreshold,
+		scaleFunc:         t.scaleFunc,
+		lazyCompress:      t.lazyCompress,
+		version:           t.version,
+	}
+}
+
+// IntercentroidDistance returns the average distance between the
+// means of adjacent centroids.
+//
+// This is a proxy for the resolution of the digest: a small spacing
+// means centroids are dense (and estimations accurate) while a large
+// one means a sparse representation. If the result is large relative
+// to the range of the data, consider increasing the compression.
+// Returns NaN if the digest has less than two centroids.
+func (t *TDigest) IntercentroidDistance() float64 {
+	n := t.summary.Len()
+	if n < 2 {
+		return math.NaN()
+	}
+	// Means are sorted, so the sum of the distances telescopes
+	return (t.Max() - t.Min()) / float64(n-1)
+}
+
+// Prefill primes the digest with centroids that reproduce the given
+// quantiles, such as "p50=100ms, p90=200ms, p99=500ms".
+//
+// The i-th value is the known estimation for the i-th quantile (in the
+// [0, 1] range, like for Quantile) of a distribution with `count`
+// samples. For each pair of adjacent quantiles a centroid is added in
+// the middle of their values, weighted by the fraction of the samples
+// between them. This is useful for seeding a digest (e.g.: for
+// alerting) before real data arrives; its accuracy is obviously limited
+// by how many quantiles are known.
+//
+// This will emit an error if the slices have different or less than
+// two elements, if the quantiles are not sorted in the [0, 1] range or
+// if the values are not sorted.
+func (t *TDigest) Prefill(quantiles []float64, values []float64, count uint64) error {
+	if len(quantiles) != len(values) {
+		return fmt.Errorf("quantiles and values must have the same length")
+	}
+	if len(quantiles) < 2 {
+		return fmt.Errorf("at least two quantiles are required")
+	}
+
+	for i, q := range quantiles {
+		if q < 0 || q > 1 {
+			return fmt.Errorf("quantiles must be between 0 and 1 (inclusive), got %f", q)
+		}
+		if i > 0 && (q < quantiles[i-1] || values[i] < values[i-1]) {
+			return fmt.Errorf("quantiles and values must be sorted")
+		}
+	}
+
+	for i := 1; i < len(quantiles); i++ {
+		weight := uint64(math.Round((quantiles[i] - quantiles[i-1]) * float64(count)))
+		if weight == 0 {
+			continue
+		}
+		err := t.AddWeighted((values[i-1]+values[i])/2, weight)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Clone returns a deep copy of a TDigest.
+//
+// Mutating the clone doesn't affect the original digest and vice versa,
+// so this can be used to take a snapshot of a live digest. The clone
+// gets its own RNG, seeded like the original one, unless a custom RNG
+// was configured via RandomNumberGenerator: since it can't be copied
+// it's shared by both digests. Clones of frozen digests are mutable.
 func (t *TDigest) Clone() *TDigest {
 	return &TDigest{
-		summary:     t.summary.Clone(),
-		compression: t.compression,
-		count:       t.count,
-		rng:         t.rng,
+		summary:        t.summary.Clone(),
+		compression:    t.compression,
+		count:          t.count,
+		rng:            cloneRNG(t.rng),
+		minCompression: t.minCompression,
+		maxCompression: t.maxCompression,
+		maxCentroids:   t.maxCentroids,
+
+		compressThreshold: t.compressThreshold,
+		scaleFunc:         t.scaleFunc,
+		lazyCompress:      t.lazyCompress,
+		needsCompress:     t.needsCompress,
+		version:           t.version,
+	}
+}
+
+// Reset empties the digest while keeping its configuration (the
+// compression and the RNG) and the capacity of its internal buffers.
+//
+// A reset digest behaves just like a freshly created one, but reusing
+// it avoids allocations: useful for per-window digests, or together
+// with a Pool. When using AdaptiveCompression, the compression goes
+// back to its minimum. Frozen digests become mutable again.
+func (t *TDigest) Reset() {
+	t.summary.means = t.summary.means[:0]
+	t.summary.counts = t.summary.counts[:0]
+	t.count = 0
+	t.compressedAt = 0
+	t.needsCompress = false
+	t.snapshot = nil
+	if t.maxCompression > 0 {
+		t.compression = t.minCompression
 	}
+	t.frozen = false
+}
+
+// Freeze compresses the digest and makes it read-only, returning it.
+//
+// Methods that would modify a frozen digest (Add, AddWeighted, Merge,
+// Compress, the deserialization methods, etc) return ErrFrozen instead,
+// while queries (Quantile, CDF, ForEachCentroid, AsBytes, ToBytes, etc)
+// work normally. Since queries don't modify the digest, a frozen digest
+// can be queried from several goroutines at once.
+//
+// Freezing is meant for serving a digest after it's done collecting
+// samples, e.g.: at the end of a time window. Use Clone to get a
+// mutable copy, or Reset to reuse the digest.
+func (t *TDigest) Freeze() *TDigest {
+	if !t.frozen {
+		// Can't fail: the centroids are known to be valid
+		_ = t.Compress()
+		t.frozen = true
+	}
+	return t
+}
+
+// Validate checks the internal consistency of the digest, returning
+// an error describing the first broken invariant found.
+//
+// A digest is valid if its compression is at least 1 and its centroids
+// have finite, sorted means and non-zero counts that add up to Count().
+// Digests are kept valid by this package, so this is not needed in
+// regular usage, but it's useful for test harnesses and after
+// deserializing data from untrusted sources.
+func (t *TDigest) Validate() error {
+	if math.IsNaN(t.compression) || t.compression < 1 {
+		return fmt.Errorf("invalid compression: %f", t.compression)
+	}
+
+	if t.summary == nil {
+		return fmt.Errorf("missing summary")
+	}
+
+	if len(t.summary.means) != len(t.summary.counts) {
+		return fmt.Errorf("got %d means but %d counts", len(t.summary.means), len(t.summary.counts))
+	}
+
+	var total uint64
+	for i, mean := range t.summary.means {
+		if math.IsNaN(mean) || math.IsInf(mean, 0) {
+			return fmt.Errorf("centroid %d has an invalid mean: %f", i, mean)
+		}
+		if i > 0 && mean < t.summary.means[i-1] {
+			return fmt.Errorf("centroids are not sorted: centroid %d has mean %f, but the previous one has %f",
+				i, mean, t.summary.means[i-1])
+		}
+
+		count := t.summary.counts[i]
+		if count == 0 {
+			return fmt.Errorf("centroid %d has a zero count", i)
+		}
+		if total+count < total {
+			return fmt.Errorf("the sum of the centroid counts overflows")
+		}
+		total += count
+	}
+
+	if total != t.count {
+		return fmt.Errorf("the centroid counts add up to %d, but the count is %d", total, t.count)
+	}
+	return nil
+}
+
+// Checksum returns a hash of the centroids of the digest.
+//
+// Digests holding exactly the same centroids have the same checksum,
+// regardless of their compression or RNG, so it's a cheap way of
+// detecting changes (e.g.: for cache invalidation). The checksum is
+// NOT cryptographic and it may change across versions of this package,
+// so it shouldn't be persisted.
+func (t *TDigest) Checksum() uint64 {
+	h := fnv.New64a()
+
+	var buf [16]byte
+	for i, mean := range t.summary.means {
+		binary.LittleEndian.PutUint64(buf[0:8], math.Float64bits(mean))
+		binary.LittleEndian.PutUint64(buf[8:16], t.summary.counts[i])
+		_, _ = h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// Equal tells whether both digests have the same compression and
+// exactly the same centroids (so the same count too). Two nil digests
+// are equal, a nil and a non-nil one are not.
+//
+// Other settings (e.g.: the RNG) are not compared. Notice that the
+// binary serialization (AsBytes) stores means with float32 precision,
+// so a round-trip through it usually yields a digest that's close to
+// the original but not Equal; use MarshalJSON for exact round-trips.
+func (t *TDigest) Equal(other *TDigest) bool {
+	if t == nil || other == nil {
+		return t == other
+	}
+
+	if t.compression != other.compression ||
+		t.count != other.count ||
+		t.summary.Len() != other.summary.Len() {
+		return false
+	}
+
+	for i, mean := range t.summary.means {
+		if mean != other.summary.means[i] || t.summary.counts[i] != other.summary.counts[i] {
+			return false
+		}
+	}
+	return true
 }
 
 func interpolate(x, x0, x1 float64) float64 {
@@ -354,6 +1714,74 @@ func (t *TDigest) ForEachCentroid(f func(mean float64, count uint64) bool) {
 	t.summary.ForEach(f)
 }
 
+// Centroids returns a copy of all the centroids of the digest, sorted
+// by mean, or nil if the digest is empty.
+//
+// This is handier than ForEachCentroid when the whole list is needed
+// (e.g.: for debugging, visualization or custom serialization), at the
+// cost of allocating it. The result can be fed to NewFromCentroids.
+func (t *TDigest) Centroids() []CentroidSnapshot {
+	if t.summary.Len() == 0 {
+		return nil
+	}
+
+	centroids := make([]CentroidSnapshot, t.summary.Len())
+	for i := range centroids {
+		centroids[i] = CentroidSnapshot{Mean: t.summary.Mean(i), Count: t.summary.Count(i)}
+	}
+	return centroids
+}
+
+// ForEachCentroidReverse works like ForEachCentroid, but walks the
+// centroids from the largest mean to the smallest.
+func (t *TDigest) ForEachCentroidReverse(f func(mean float64, count uint64) bool) {
+	for i := t.summary.Len() - 1; i >= 0; i-- {
+		if !f(t.summary.Mean(i), t.summary.Count(i)) {
+			break
+		}
+	}
+}
+
+// ForEachCentroidWithCumulativeCount works like ForEachCentroid, but
+// also passes the number of samples in all the centroids before the
+// current one (so it's 0 for the first centroid).
+//
+// This makes it easy to locate the quantile each centroid covers
+// without keeping track of the running sum: the centroid spans from
+// cumCount/Count() to (cumCount+count)/Count().
+func (t *TDigest) ForEachCentroidWithCumulativeCount(f func(mean float64, count uint64, cumCount uint64) bool) {
+	var cumCount uint64
+	t.summary.ForEach(func(mean float64, count uint64) bool {
+		more := f(mean, count, cumCount)
+		cumCount += count
+		return more
+	})
+}
+
+// Len returns the number of centroids in the digest.
+//
+// The serialized size of a digest grows linearly with it, so it can be
+// used e.g.: to decide whether to Compress before serializing.
+//
+// Len, Less and Swap make TDigest implement sort.Interface over its
+// centroids, ordered by mean. The digest keeps its centroids sorted
+// on its own, so calling sort.Sort is only ever needed to restore
+// the order after manipulating centroids directly.
+func (t *TDigest) Len() int {
+	return t.summary.Len()
+}
+
+// Less reports whether the centroid at index i has a lower mean
+// than the one at index j.
+func (t *TDigest) Less(i, j int) bool {
+	return t.summary.Less(i, j)
+}
+
+// Swap swaps the centroids at indexes i and j.
+func (t *TDigest) Swap(i, j int) {
+	t.summary.Swap(i, j)
+}
+
 func (t TDigest) findNeighbors(start int, value float64) (int, int) {
 	minDistance := math.MaxFloat64
 	lastNeighbor := t.summary.Len()
@@ -383,7 +1811,12 @@ func (t TDigest) chooseMergeCandidate(begin, end int, count uint64) int {
 		} else {
 			q = (sum + (c-1)/2) / float64(t.count-1)
 		}
-		k := 4 * float64(t.count) * q * (1 - q) / t.compression
+		var k float64
+		if t.scaleFunc == nil {
+			k = 4 * float64(t.count) * q * (1 - q) / t.compression
+		} else {
+			k = float64(t.count) * t.scaleFunc(q, t.compression)
+		}
 
 		if c+float64(count) <= k {
 			n++
@@ -447,6 +1880,275 @@ func (t *TDigest) TrimmedMean(p1, p2 float64) float64 {
 	return trimmedSum / trimmedCount
 }
 
+// TruncatedMean returns the mean of the samples between the quantiles
+// lo and hi, an outlier-resistant alternative to Mean: e.g.:
+// TruncatedMean(0.05, 0.95) ignores the 5% lowest and highest samples.
+// Centroids that straddle the boundaries are weighted by the fraction
+// of their samples that fall within them.
+//
+// It's equivalent to TrimmedMean, except that this returns NaN for
+// empty digests and when lo >= hi instead of 0 and panicking,
+// respectively. Values of lo and hi must be between 0 and 1
+// (inclusive), will panic otherwise.
+func (t *TDigest) TruncatedMean(lo, hi float64) float64 {
+	if t.count == 0 || !(lo < hi) {
+		return math.NaN()
+	}
+	return t.TrimmedMean(lo, hi)
+}
+
+// Mean returns the mean of all samples in the digest, or NaN if it's
+// empty.
+//
+// Since centroids hold the exact mean of the samples merged into them,
+// this is not an estimation: it's exact, modulo floating point
+// accumulation errors.
+func (t *TDigest) Mean() float64 {
+	if t.count == 0 {
+		return math.NaN()
+	}
+	return t.sum() / float64(t.count)
+}
+
+// Variance returns the (population) variance of the samples in the
+// digest, or NaN if it holds less than two samples.
+//
+// This is computed from the centroids, as the weighted variance of
+// their means, so it's an underestimation: the spread of the samples
+// merged within each centroid is lost. The error is small with the
+// usual compression values since centroids are small relative to the
+// range of the data, e.g.: it's well under 1% for normally distributed
+// data with the default compression.
+func (t *TDigest) Variance() float64 {
+	if t.count < 2 {
+		return math.NaN()
+	}
+	return t.variance(t.Mean())
+}
+
+// StdDev returns the (population) standard deviation of the samples
+// in the digest, or NaN if it holds less than two samples. Refer to
+// Variance for more details.
+func (t *TDigest) StdDev() float64 {
+	return math.Sqrt(t.Variance())
+}
+
+// MAD returns the median absolute deviation of the samples in the
+// digest, i.e.: the median of |x - median|, or NaN if it's empty.
+//
+// Unlike StdDev, it's barely affected by outliers. For normally
+// distributed data it's about 0.6745 times the standard deviation.
+//
+// The deviations of the centroid means are collected into a scratch
+// digest, with the same compression, so this allocates.
+func (t *TDigest) MAD() float64 {
+	if t.summary.Len() == 0 {
+		return math.NaN()
+	}
+
+	deviations, err := New(Compression(t.compression))
+	if err != nil {
+		return math.NaN()
+	}
+
+	// Deviations decrease then increase along the centroids, so
+	// they're added in random order. The scratch RNG is used to keep
+	// this safe for frozen digests, which may be queried concurrently
+	median := t.Quantile(0.5)
+	t.summary.Perm(deviations.rng, func(mean float64, count uint64) bool {
+		err = deviations.AddWeighted(math.Abs(mean-median), count)
+		return err == nil
+	})
+	if err != nil {
+		return math.NaN()
+	}
+	return deviations.Quantile(0.5)
+}
+
+// Skewness returns the skewness (third standardized moment) of the
+// samples in the digest, or NaN if it holds less than two centroids.
+//
+// Like Variance, this is computed from the centroids, so the spread
+// of the samples merged within each centroid is lost. Since tails are
+// kept as small centroids, the estimation is good enough to tell
+// symmetric distributions (skewness 0) from right-skewed ones like
+// latencies (positive skewness).
+func (t *TDigest) Skewness() float64 {
+	if t.summary.Len() < 2 {
+		return math.NaN()
+	}
+	m2, m3, _ := t.centralMoments()
+	return m3 / math.Pow(m2, 1.5)
+}
+
+// Kurtosis returns the kurtosis (fourth standardized moment) of the
+// samples in the digest, or NaN if it holds less than two centroids.
+// Refer to Skewness for more details.
+//
+// This is not the excess kurtosis: it's 3 for normally distributed
+// data and higher for heavy-tailed distributions.
+func (t *TDigest) Kurtosis() float64 {
+	if t.summary.Len() < 2 {
+		return math.NaN()
+	}
+	m2, _, m4 := t.centralMoments()
+	return m4 / (m2 * m2)
+}
+
+// Entropy returns an estimation of the (differential) Shannon
+// entropy of the distribution of the samples, in nats. Returns NaN for
+// empty digests and 0 for digests holding a single centroid.
+//
+// The density is assumed to be uniform between the means of adjacent
+// centroids, so the result is -sum(p * log(p / width)) over those
+// intervals, with p the fraction of the samples that falls within
+// each one according to the CDF. For integer data the intervals are
+// (roughly) unit-wide, so it approximates the entropy of the values
+// themselves, e.g.: log(1000) for 1000 equally likely values. Unlike
+// the entropy of discrete values, it's negative for distributions
+// concentrated within less than a unit.
+func (t *TDigest) Entropy() float64 {
+	if t.summary.Len() == 0 {
+		return math.NaN()
+	} else if t.summary.Len() == 1 {
+		return 0
+	}
+
+	cdfs := t.CDFs(t.summary.means)
+	total := cdfs[len(cdfs)-1] - cdfs[0]
+
+	var entropy float64
+	for i := 1; i < len(cdfs); i++ {
+		width := t.summary.Mean(i) - t.summary.Mean(i-1)
+		p := (cdfs[i] - cdfs[i-1]) / total
+		if width > 0 && p > 0 {
+			entropy -= p * math.Log(p/width)
+		}
+	}
+	return entropy
+}
+
+// DigestStats holds a summary of the distribution of a digest, as
+// returned by Summarize.
+type DigestStats struct {
+	Count uint64
+
+	Min, Max, Mean float64
+	Median         float64
+	P90, P95, P99  float64
+	P999           float64
+
+	Centroids   int
+	Compression float64
+}
+
+// Summarize returns the most commonly needed statistics of the digest
+// at once, e.g.: for logging or health checks. All quantiles are
+// computed in a single pass, via Quantiles.
+//
+// The floating point fields are NaN for empty digests.
+func (t *TDigest) Summarize() DigestStats {
+	quantiles := t.Quantiles([]float64{0.5, 0.9, 0.95, 0.99, 0.999})
+
+	return DigestStats{
+		Count:       t.count,
+		Min:         t.Min(),
+		Max:         t.Max(),
+		Mean:        t.Mean(),
+		Median:      quantiles[0],
+		P90:         quantiles[1],
+		P95:         quantiles[2],
+		P99:         quantiles[3],
+		P999:        quantiles[4],
+		Centroids:   t.summary.Len(),
+		Compression: t.compression,
+	}
+}
+
+// DigestInfo holds diagnostic metadata about a digest, as returned by
+// Inspect.
+type DigestInfo struct {
+	Count       uint64
+	Centroids   int
+	Compression float64
+
+	// Centroids over the number of centroids that triggers a
+	// compression (see CompressAfter). Never above 1 unless a
+	// compression is pending (see LazyCompress)
+	UncompressedRatio float64
+
+	// See MemoryUsage
+	MemoryEstimate int
+
+	IsLazyCompress bool
+	IsFrozen       bool
+}
+
+// Inspect returns diagnostic metadata about the digest, e.g.: for
+// exporting as metrics. A ratio of centroids that often approaches 1
+// means compressions are frequent, so raising the threshold (or
+// lowering the compression) makes additions cheaper.
+func (t *TDigest) Inspect() DigestInfo {
+	return DigestInfo{
+		Count:             t.count,
+		Centroids:         t.summary.Len(),
+		Compression:       t.compression,
+		UncompressedRatio: float64(t.summary.Len()) / t.compressionThreshold(),
+		MemoryEstimate:    t.MemoryUsage(),
+		IsLazyCompress:    t.lazyCompress,
+		IsFrozen:          t.frozen,
+	}
+}
+
+// MemoryUsage returns an estimation of the number of bytes held by
+// the digest: its own struct plus the arrays backing the centroids,
+// which are sized after the compression. The RNG is not accounted for
+// since it may be shared (the default local one holds about 5KB).
+func (t *TDigest) MemoryUsage() int {
+	return int(unsafe.Sizeof(*t)+unsafe.Sizeof(*t.summary)) +
+		cap(t.summary.means)*int(unsafe.Sizeof(float64(0))) +
+		cap(t.summary.counts)*int(unsafe.Sizeof(uint64(0)))
+}
+
+// Computes the (exact, modulo floating point accumulation errors)
+// sum of all samples in the digest.
+func (t *TDigest) sum() float64 {
+	var sum float64
+	for i, mean := range t.summary.means {
+		sum += mean * float64(t.summary.counts[i])
+	}
+	return sum
+}
+
+// Computes the variance of the centroid means, weighted by their
+// counts, around the given mean.
+func (t *TDigest) variance(mean float64) float64 {
+	var sum float64
+	for i, m := range t.summary.means {
+		sum += float64(t.summary.counts[i]) * (m - mean) * (m - mean)
+	}
+	return sum / float64(t.count)
+}
+
+// Computes the second, third and fourth central moments of the
+// centroid means, weighted by their counts.
+func (t *TDigest) centralMoments() (m2, m3, m4 float64) {
+	mean := t.Mean()
+	for i, m := range t.summary.means {
+		c := float64(t.summary.counts[i])
+		d := m - mean
+		m2 += c * d * d
+		m3 += c * d * d * d
+		m4 += c * d * d * d * d
+	}
+	n := float64(t.count)
+	return m2 / n, m3 / n, m4 / n
+}
+
+// Roughly how many centroids a compressed digest holds per unit of
+// compression.
+const centroidsPerCompression = 10
+
 func estimateCapacity(compression float64) int {
-	return int(compression) * 10
+	return int(compression) * centroidsPerCompression
 }
@@ -1,11 +1,17 @@
 package tdigest
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
+	"reflect"
+	"runtime"
 	"sort"
+	"strings"
 	"testing"
+	"testing/quick"
 
 	rng "github.com/leesper/go_rng"
 	"gonum.org/v1/gonum/stat"
@@ -501,6 +507,112 @@ func TestForEachCentroid(t *testing.T) {
 	}
 }
 
+func TestCentroids(t *testing.T) {
+	tdigest := uncheckedNew(Compression(10))
+	if tdigest.Centroids() != nil {
+		t.Errorf("Expected nil centroids for empty digests")
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	for i := 0; i < 1000; i++ {
+		_ = tdigest.Add(rng.Float64())
+	}
+
+	centroids := tdigest.Centroids()
+	if len(centroids) != tdigest.Len() {
+		t.Fatalf("Expected %d centroids, got %d", tdigest.Len(), len(centroids))
+	}
+
+	var count uint64
+	for i, c := range centroids {
+		if i > 0 && c.Mean < centroids[i-1].Mean {
+			t.Errorf("Expected means in non-decreasing order, got %f after %f", c.Mean, centroids[i-1].Mean)
+		}
+		count += c.Count
+	}
+
+	if count != tdigest.Count() {
+		t.Errorf("Expected counts to sum to %d, got %d", tdigest.Count(), count)
+	}
+
+	// It's a copy
+	centroids[0].Mean = -1
+	if tdigest.Min() == -1 {
+		t.Errorf("Expected Centroids() to return a copy")
+	}
+}
+
+func TestForEachCentroidReverse(t *testing.T) {
+	tdigest := uncheckedNew(Compression(10))
+
+	for i := 0; i < 100; i++ {
+		_ = tdigest.Add(float64(i))
+	}
+
+	// Iterate limited number.
+	means := []float64{}
+	tdigest.ForEachCentroidReverse(func(mean float64, count uint64) bool {
+		means = append(means, mean)
+		return len(means) != 3
+	})
+	if len(means) != 3 || means[0] != tdigest.Max() {
+		t.Errorf("ForEachCentroidReverse handled incorrect number of data items")
+	}
+
+	// Iterate all datapoints.
+	means = []float64{}
+	tdigest.ForEachCentroidReverse(func(mean float64, count uint64) bool {
+		if len(means) > 0 && mean >= means[len(means)-1] {
+			t.Errorf("Expected strictly decreasing means, got %f after %f", mean, means[len(means)-1])
+		}
+		means = append(means, mean)
+		return true
+	})
+	if len(means) != tdigest.summary.Len() {
+		t.Errorf("ForEachCentroidReverse did not handle all data")
+	}
+}
+
+func TestForEachCentroidWithCumulativeCount(t *testing.T) {
+	tdigest := uncheckedNew(Compression(10))
+
+	for i := 0; i < 100; i++ {
+		_ = tdigest.Add(float64(i))
+	}
+
+	// Iterate limited number.
+	calls := 0
+	tdigest.ForEachCentroidWithCumulativeCount(func(mean float64, count uint64, cumCount uint64) bool {
+		calls++
+		return calls != 3
+	})
+	if calls != 3 {
+		t.Errorf("ForEachCentroidWithCumulativeCount handled incorrect number of data items")
+	}
+
+	// Iterate all datapoints.
+	calls = 0
+	var expected, last uint64
+	tdigest.ForEachCentroidWithCumulativeCount(func(mean float64, count uint64, cumCount uint64) bool {
+		if cumCount != expected || (calls > 0 && cumCount <= last) {
+			t.Errorf("Unexpected cumCount %d for centroid %d (wanted %d)", cumCount, calls, expected)
+		}
+		last = cumCount
+		expected += count
+		calls++
+		return true
+	})
+
+	if calls != tdigest.summary.Len() {
+		t.Errorf("ForEachCentroidWithCumulativeCount did not handle all data")
+	}
+
+	lastCount := tdigest.summary.counts[tdigest.summary.Len()-1]
+	if last != tdigest.Count()-lastCount {
+		t.Errorf("Expected cumCount of the last centroid to be %d, got %d", tdigest.Count()-lastCount, last)
+	}
+}
+
 func TestQuantilesDontOverflow(t *testing.T) {
 	tdigest := uncheckedNew(Compression(100))
 	// Add slightly more than math.MaxUint32 samples uniformly in the range
@@ -676,163 +788,2501 @@ func TestClone(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-}
 
-var compressions = []float64{1, 10, 20, 30, 50, 100}
+	if clone.Count() != cloneCount+1 || td.Count() != 2*cloneCount {
+		t.Fatalf("Expected the counts to diverge, got %d and %d", clone.Count(), td.Count())
+	}
 
-func BenchmarkTDigestAddOnce(b *testing.B) {
-	for _, compression := range compressions {
-		compression := compression
-		b.Run(fmt.Sprintf("compression=%.0f", compression), func(b *testing.B) {
-			benchmarkAddOnce(b, compression)
-		})
+	// The RNG is not shared, unless it's a custom one
+
+	if clone.rng == td.rng {
+		t.Fatalf("Expected the clone to have its own RNG")
+	}
+
+	shared := globalRNG{}
+	td = uncheckedNew(RandomNumberGenerator(shared))
+	if td.Clone().rng != shared {
+		t.Fatalf("Expected custom RNGs to be shared")
 	}
 }
 
-func benchmarkAddOnce(b *testing.B, compression float64) {
-	t := uncheckedNew(Compression(compression))
+func TestPartialMerge(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0xCA10))
 
-	data := make([]float64, b.N)
-	for n := 0; n < b.N; n++ {
-		data[n] = rand.Float64()
+	other := uncheckedNew()
+	for i := 0; i < 1000; i++ {
+		_ = other.AddWeighted(rnd.Float64(), 10)
 	}
 
-	b.ReportAllocs()
-	b.ResetTimer()
-	for n := 0; n < b.N; n++ {
-		err := t.Add(data[n])
-		if err != nil {
-			b.Error(err)
+	base := make([]float64, 1000)
+	for i := range base {
+		base[i] = rnd.Float64()
+	}
+	seed := func() *TDigest {
+		td := uncheckedNew(LocalRandomNumberGenerator(42))
+		for _, v := range base {
+			_ = td.Add(v)
 		}
+		return td
 	}
-	b.StopTimer()
-}
 
-func BenchmarkTDigestAddMulti(b *testing.B) {
-	for _, compression := range compressions {
-		compression := compression
-		for _, n := range []int{10, 100, 1000, 10000} {
-			n := n
-			name := fmt.Sprintf("compression=%.0f n=%d", compression, n)
-			b.Run(name, func(b *testing.B) {
-				benchmarkAddMulti(b, compression, n)
-			})
-		}
+	// A full weight behaves exactly like Merge
+	merged := seed()
+	if err := merged.Merge(other); err != nil {
+		t.Fatal(err)
+	}
+	full := seed()
+	if err := full.PartialMerge(other, 1.0); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(merged.summary, full.summary) || merged.Count() != full.Count() {
+		t.Errorf("PartialMerge(other, 1) should be equivalent to Merge(other)")
 	}
-}
 
-func benchmarkAddMulti(b *testing.B, compression float64, times int) {
-	data := make([]float64, times)
-	for i := 0; i < times; i++ {
-		data[i] = rand.Float64()
+	// Two halves are approximately the whole
+	halves := seed()
+	for i := 0; i < 2; i++ {
+		if err := halves.PartialMerge(other, 0.5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if halves.Count() != merged.Count() {
+		t.Errorf("Expected count %d, got %d", merged.Count(), halves.Count())
+	}
+	for _, q := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+		if math.Abs(halves.Quantile(q)-merged.Quantile(q)) > 0.01 {
+			t.Errorf("Quantile(%.2f) differs too much: %.4f vs %.4f", q, halves.Quantile(q), merged.Quantile(q))
+		}
 	}
 
-	b.ReportAllocs()
-	b.ResetTimer()
-	for n := 0; n < b.N; n++ {
-		t := uncheckedNew(Compression(compression))
-		for i := 0; i < times; i++ {
-			err := t.AddWeighted(data[i], 1)
-			if err != nil {
-				b.Error(err)
-			}
+	for _, weight := range []float64{0, -0.5, 1.1, math.NaN()} {
+		if halves.PartialMerge(other, weight) == nil {
+			t.Errorf("Expected PartialMerge() to error out with weight=%f", weight)
 		}
 	}
-	b.StopTimer()
 }
 
-func BenchmarkTDigestMerge(b *testing.B) {
-	for _, compression := range compressions {
-		compression := compression
-		for _, n := range []int{1, 10, 100} {
-			name := fmt.Sprintf("compression=%.0f n=%d", compression, n)
-			b.Run(name, func(b *testing.B) {
-				benchmarkMerge(b, compression, n)
-			})
-		}
+func TestCompressTo(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0xCA10))
+	tdigest := uncheckedNew()
+
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = rnd.Float64()
+		_ = tdigest.Add(data[i])
+	}
+	sort.Float64s(data)
+
+	if tdigest.summary.Len() <= 50 {
+		t.Fatalf("Expected more than 50 centroids before CompressTo, got %d", tdigest.summary.Len())
+	}
+
+	err := tdigest.CompressTo(50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tdigest.summary.Len() > 50 {
+		t.Errorf("Expected at most 50 centroids, got %d", tdigest.summary.Len())
+	}
+
+	if tdigest.Compression() != 100 {
+		t.Errorf("CompressTo() should not change the configured compression. Got %.2f", tdigest.Compression())
+	}
+
+	if tdigest.Count() != uint64(len(data)) {
+		t.Errorf("CompressTo() should not change count. Wanted %d, got %d", len(data), tdigest.Count())
+	}
+
+	// A coarser digest is less accurate, but still usable: the
+	// absolute error stays within 0.5% for the uniform distribution
+	for _, q := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+		assertDifferenceFromQuantile(data, tdigest, q, 0.005, t)
+	}
+
+	if tdigest.CompressTo(0) == nil {
+		t.Errorf("Expected CompressTo() to error out with targetCentroids=0")
 	}
 }
 
-func benchmarkMerge(b *testing.B, compression float64, times int) {
-	ts := make([]*TDigest, times)
-	for i := 0; i < times; i++ {
-		ts[i] = randomTDigest(compression)
+func TestCompact(t *testing.T) {
+	if compact := uncheckedNew(Compression(42)).Compact(); compact.Compression() != 42 || compact.Count() != 0 {
+		t.Errorf("Expected empty digests to keep their compression, got %.0f", compact.Compression())
 	}
 
-	b.ReportAllocs()
-	b.ResetTimer()
-	for n := 0; n < b.N; n++ {
-		dst := uncheckedNew(Compression(compression))
+	// Over-compressed: 10 centroids, compression 1000
+	rnd := rand.New(rand.NewSource(0xCA10))
+	tdigest := uncheckedNew(Compression(1000))
+	for i := 0; i < 100000; i++ {
+		_ = tdigest.Add(rnd.NormFloat64())
+	}
+	if err := tdigest.CompressTo(10); err != nil {
+		t.Fatal(err)
+	}
+	if tdigest.Compression() != 1000 || tdigest.Len() > 10 {
+		t.Fatalf("Expected at most 10 centroids with compression 1000, got %d", tdigest.Len())
+	}
+	before := tdigest.Checksum()
 
-		for i := 0; i < times; i++ {
-			err := dst.Merge(ts[i])
-			if err != nil {
-				b.Fatal(err)
-			}
+	compact := tdigest.Freeze().Compact()
+	if math.Abs(compact.Compression()-10) >= math.Abs(tdigest.Compression()-10) {
+		t.Errorf("Expected a compression closer to 10, got %.0f", compact.Compression())
+	}
+	if compact.Count() != tdigest.Count() || compact.Len() != tdigest.Len() {
+		t.Errorf("Expected the same centroids, got %d and %d", compact.Count(), compact.Len())
+	}
+
+	for _, q := range []float64{0, 0.001, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999, 1} {
+		if compact.Quantile(q) != tdigest.Quantile(q) {
+			t.Errorf("Expected Quantile(%.3f) = %f, got %f", q, tdigest.Quantile(q), compact.Quantile(q))
 		}
+	}
 
-		err := dst.Compress()
-		if err != nil {
-			b.Fatal(err)
+	if tdigest.Checksum() != before {
+		t.Errorf("Compact() should leave the digest untouched")
+	}
+
+	// The compact copy is mutable and keeps working
+	for i := 0; i < 1000; i++ {
+		if err := compact.Add(rnd.NormFloat64()); err != nil {
+			t.Fatal(err)
 		}
 	}
+	if compact.Count() != 101000 || compact.Validate() != nil {
+		t.Errorf("Expected a valid digest with 101000 samples")
+	}
+
+	// Adjusting to the data goes both ways
+	lazy := uncheckedNew(Compression(10), LazyCompress())
+	for i := 0; i < 5000; i++ {
+		_ = lazy.Add(float64(i))
+	}
+	if compression := lazy.Compact().Compression(); compression != 500 {
+		t.Errorf("Expected 5000 centroids to yield a compression of 500, got %.0f", compression)
+	}
 }
 
-func randomTDigest(compression float64) *TDigest {
-	t := uncheckedNew(Compression(compression))
-	n := 20 * int(compression)
-	for i := 0; i < n; i++ {
-		err := t.Add(rand.Float64())
-		if err != nil {
-			panic(err)
-		}
+func TestReserve(t *testing.T) {
+	tdigest := uncheckedNew(Compression(50))
+
+	if tdigest.Reserve(0) == nil {
+		t.Errorf("Expected Reserve() to error out with n=0")
+	}
+
+	_ = tdigest.Add(-1)
+	if err := tdigest.Reserve(1000); err != nil {
+		t.Fatal(err)
+	}
+
+	if cap(tdigest.summary.means) < 1000 || cap(tdigest.summary.counts) < 1000 {
+		t.Fatalf("Reserve() did not grow the summary. cap=%d", cap(tdigest.summary.means))
+	}
+
+	if tdigest.summary.Len() != 1 || tdigest.summary.Mean(0) != -1 {
+		t.Fatalf("Reserve() should preserve existing centroids")
+	}
+
+	data := make([]float64, 900)
+	for i := range data {
+		data[i] = float64(i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for _, x := range data {
+		_ = tdigest.Add(x)
+	}
+	runtime.ReadMemStats(&after)
+
+	if after.Mallocs != before.Mallocs {
+		t.Errorf("Expected no allocations after Reserve(), got %d", after.Mallocs-before.Mallocs)
 	}
-	return t
 }
 
-// Pathological ordered-input case.
-func BenchmarkAddOrdered(b *testing.B) {
-	t, _ := New(Compression(100))
+func TestCumulativeDensity(t *testing.T) {
+	tdigest := uncheckedNew()
 
-	for n := 0; n < b.N; n++ {
-		err := t.Add(float64(n))
-		if err != nil {
-			b.Error(err)
+	if !math.IsNaN(tdigest.CumulativeDensity(1)) {
+		t.Errorf("CumulativeDensity() on an empty digest should return NaN")
+	}
+
+	for i := 0; i < 1000; i++ {
+		_ = tdigest.Add(5)
+	}
+
+	if tdigest.CDF(5) != 1 {
+		t.Errorf("Expected CDF(5) = 1, got %.4f", tdigest.CDF(5))
+	}
+
+	if tdigest.CumulativeDensity(5) != 0 {
+		t.Errorf("Expected CumulativeDensity(5) = 0, got %.4f", tdigest.CumulativeDensity(5))
+	}
+
+	if tdigest.CumulativeDensity(5.1) != 1 {
+		t.Errorf("Expected CumulativeDensity(5.1) = 1, got %.4f", tdigest.CumulativeDensity(5.1))
+	}
+
+	tdigest = uncheckedNew()
+	for i := 0; i < 10; i++ {
+		_ = tdigest.Add(float64(i % 3))
+	}
+
+	// Four samples are strictly less than 1: 0, 0, 0 and 0. CDF spreads
+	// the centroids around their means, so this is only approximate
+	if cd := tdigest.CumulativeDensity(1); math.Abs(cd-0.4) > 0.05+1e-9 {
+		t.Errorf("Expected CumulativeDensity(1) close to 0.4, got %.4f", cd)
+	}
+
+	// The strict estimate never exceeds CDF, even with uneven spacing
+	uneven := uncheckedNew()
+	_ = uneven.AddWeighted(0, 1)
+	_ = uneven.AddWeighted(1, 10)
+	_ = uneven.AddWeighted(100, 1)
+	for _, digest := range []*TDigest{tdigest, uneven} {
+		for _, x := range []float64{-1, 0, 0.5, 1, 1.5, 2, 3, 100} {
+			if cd := digest.CumulativeDensity(x); cd < 0 || cd > digest.CDF(x) {
+				t.Errorf("Expected 0 <= CumulativeDensity(%.1f) <= CDF(%.1f), got %.4f and %.4f",
+					x, x, cd, digest.CDF(x))
+			}
+		}
+	}
+
+	// Away from the centroid means both functions agree
+	for _, x := range []float64{-1, 0.5, 1.5, 3} {
+		if tdigest.CumulativeDensity(x) != tdigest.CDF(x) {
+			t.Errorf("Expected CumulativeDensity(%.1f) = CDF(%.1f), got %.4f != %.4f",
+				x, x, tdigest.CumulativeDensity(x), tdigest.CDF(x))
 		}
 	}
 }
 
-func BenchmarkMerge(b *testing.B) {
-	b.ReportAllocs()
+func TestFlushTo(t *testing.T) {
+	src := uncheckedNew()
+	dst := uncheckedNew()
+	for i := 0; i < 100; i++ {
+		_ = src.Add(float64(i))
+		_ = dst.Add(float64(i))
+	}
 
-	t, _ := New(Compression(100))
-	for n := 0; n < 1000; n++ {
-		t.AddWeighted(rand.Float64(), uint64(rand.Intn(100)))
+	err := src.FlushTo(dst)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	dest, _ := New(Compression(100))
+	if src.Count() != 0 || src.summary.Len() != 0 {
+		t.Errorf("Expected an empty digest after FlushTo(), got count=%d", src.Count())
+	}
 
-	b.ResetTimer()
-	for n := 0; n < b.N; n++ {
-		dest.Merge(t)
+	if dst.Count() != 200 {
+		t.Errorf("Expected dst count to be 200, got %d", dst.Count())
 	}
-}
 
-func BenchmarkMergeDestructive(b *testing.B) {
-	b.ReportAllocs()
+	// The flushed digest is still usable
+	_ = src.Add(1)
+	if src.Count() != 1 {
+		t.Errorf("Expected a flushed digest to remain usable")
+	}
 
-	t, _ := New(Compression(100))
-	for n := 0; n < 1000; n++ {
-		t.AddWeighted(rand.Float64(), uint64(rand.Intn(100)))
+	if err := dst.FlushTo(dst); err == nil || dst.Count() != 200 {
+		t.Errorf("Expected an error and no changes when flushing into itself, got %v", err)
 	}
 
-	dest, _ := New(Compression(100))
+	// A frozen destination leaves both digests untouched
+	for i := 0; i < 100; i++ {
+		_ = src.Add(float64(i))
+	}
+	before := src.Checksum()
+	dst.Freeze()
+	if err := src.FlushTo(dst); err != ErrFrozen || dst.Count() != 200 || src.Checksum() != before {
+		t.Errorf("Expected ErrFrozen and no changes, got %v", err)
+	}
 
-	b.ResetTimer()
+	// A broken digest can't be flushed, and isn't touched
+	broken := uncheckedNew()
+	broken.summary = &summary{
+		means:  []float64{1, math.NaN()},
+		counts: []uint64{1, 1},
+	}
+	broken.count = 2
 
-	// After the first iteration, t's summary is scrambled, which means it's
+	err = broken.FlushTo(uncheckedNew())
+	if err == nil {
+		t.Fatalf("Expected FlushTo() to fail when merging a NaN centroid")
+	}
+
+	if broken.Count() != 2 || broken.summary.Len() != 2 || broken.summary.Mean(0) != 1 {
+		t.Errorf("FlushTo() should leave the digest intact when the merge fails")
+	}
+}
+
+func TestSortInterface(t *testing.T) {
+	tdigest := uncheckedNew()
+	for i := 0; i < 100; i++ {
+		_ = tdigest.Add(float64(i))
+	}
+
+	if tdigest.Len() != tdigest.summary.Len() {
+		t.Errorf("Expected Len() = %d, got %d", tdigest.summary.Len(), tdigest.Len())
+	}
+
+	tdigest.summary.shuffle(newLocalRNG(42))
+	if sort.Float64sAreSorted(tdigest.summary.means) {
+		t.Fatalf("Expected shuffled centroids to be out of order")
+	}
+
+	sort.Sort(tdigest)
+	if !sort.Float64sAreSorted(tdigest.summary.means) {
+		t.Fatalf("sort.Sort() should restore the centroid order")
+	}
+
+	for i := 0; i < tdigest.Len(); i++ {
+		if tdigest.summary.Count(i) != 1 || tdigest.summary.Mean(i) != float64(i) {
+			t.Errorf("Counts should follow their means when sorting. Got {%.0f,%d} at %d",
+				tdigest.summary.Mean(i), tdigest.summary.Count(i), i)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	tdigest := uncheckedNew()
+
+	if _, err := tdigest.Sample(10, newLocalRNG(42)); err == nil {
+		t.Errorf("Expected Sample() to error out on an empty digest")
+	}
+
+	rnd := rand.New(rand.NewSource(0xCA10))
+	for i := 0; i < 100000; i++ {
+		_ = tdigest.Add(rnd.Float64())
+	}
+
+	if _, err := tdigest.Sample(0, newLocalRNG(42)); err == nil {
+		t.Errorf("Expected Sample() to error out with n=0")
+	}
+
+	const n = 10000
+	samples, err := tdigest.Sample(n, newLocalRNG(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != n {
+		t.Fatalf("Expected %d samples, got %d", n, len(samples))
+	}
+
+	// Kolmogorov-Smirnov test against U(0,1) at a 5% significance level
+	sort.Float64s(samples)
+	var d float64
+	for i, x := range samples {
+		d = math.Max(d, math.Max(float64(i+1)/n-x, x-float64(i)/n))
+	}
+	if critical := 1.36 / math.Sqrt(n); d > critical {
+		t.Errorf("Samples don't look uniform: D=%.4f > %.4f", d, critical)
+	}
+
+	// Same rng, same samples
+	again, _ := tdigest.Sample(n, newLocalRNG(42))
+	sort.Float64s(again)
+	if !reflect.DeepEqual(samples, again) {
+		t.Errorf("Expected Sample() to be reproducible given the same rng")
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	tdigest := uncheckedNew()
+
+	if ps := tdigest.Percentiles(50, 90); len(ps) != 2 || !math.IsNaN(ps[0]) || !math.IsNaN(ps[1]) {
+		t.Errorf("Percentiles() on an empty digest should return NaNs. Got %v", ps)
+	}
+
+	for i := 0; i < 1000; i++ {
+		_ = tdigest.Add(float64(i))
+	}
+
+	ps := tdigest.Percentiles(50, 90)
+	if ps[0] != tdigest.Quantile(0.5) || ps[1] != tdigest.Quantile(0.9) {
+		t.Errorf("Expected Percentiles(50, 90) to match Quantile(). Got %v", ps)
+	}
+
+	p := 99.9
+	if ps := tdigest.Percentiles(p); len(ps) != 1 || ps[0] != tdigest.Quantile(p/100) {
+		t.Errorf("Expected a single percentile. Got %v", ps)
+	}
+
+	if ps := tdigest.Percentiles(); len(ps) != 0 {
+		t.Errorf("Expected no percentiles. Got %v", ps)
+	}
+
+	shouldPanic(func() {
+		tdigest.Percentiles(50, 101)
+	}, t, "Percentile > 100 should panic!")
+
+	shouldPanic(func() {
+		tdigest.Percentiles(-1)
+	}, t, "Percentile < 0 should panic!")
+}
+
+func TestQuantileError(t *testing.T) {
+	tdigest := uncheckedNew()
+
+	if !closeEnough(tdigest.QuantileError(0.99), 0.000099) {
+		t.Errorf("Expected QuantileError(0.99) = 0.000099, got %f", tdigest.QuantileError(0.99))
+	}
+
+	if !closeEnough(tdigest.QuantileError(0.1), tdigest.QuantileError(0.9)) {
+		t.Errorf("Expected QuantileError() to be symmetric")
+	}
+
+	if tdigest.QuantileError(0) != 0 || tdigest.QuantileError(1) != 0 {
+		t.Errorf("Expected QuantileError() to be zero on the extremes")
+	}
+
+	// Same setup as TestUniformDistribution, but comparing against the
+	// exact quantiles of the data so that sampling noise doesn't count
+	rnd := rand.New(rand.NewSource(0xCA10))
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = rnd.Float64()
+		_ = tdigest.Add(data[i])
+	}
+	sort.Float64s(data)
+
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		assertDifferenceFromQuantile(data, tdigest, q, tdigest.QuantileError(q), t)
+	}
+
+	shouldPanic(func() {
+		tdigest.QuantileError(1.1)
+	}, t, "QuantileError > 1 should panic!")
+}
+
+func TestInterpolate(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0xCA10))
+	a := uncheckedNew()
+	b := uncheckedNew()
+
+	if _, err := a.Interpolate(0.5, b, 1000); err == nil {
+		t.Errorf("Expected Interpolate() to error out on empty digests")
+	}
+
+	for i := 0; i < 10000; i++ {
+		_ = a.Add(rnd.Float64())
+		_ = b.Add(10 + 2*rnd.Float64())
+	}
+
+	for _, alpha := range []float64{0, 0.25, 0.5, 1} {
+		result, err := a.Interpolate(alpha, b, 10000)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.99} {
+			wanted := a.Quantile(q)*(1-alpha) + b.Quantile(q)*alpha
+			if got := result.Quantile(q); math.Abs(got-wanted) > 0.01 {
+				t.Errorf("alpha=%.2f: Quantile(%.2f) = %.4f, wanted %.4f", alpha, q, got, wanted)
+			}
+		}
+	}
+
+	for _, alpha := range []float64{-0.1, 1.1, math.NaN()} {
+		if _, err := a.Interpolate(alpha, b, 1000); err == nil {
+			t.Errorf("Expected Interpolate() to error out with alpha=%f", alpha)
+		}
+	}
+
+	if _, err := a.Interpolate(0.5, b, 0); err == nil {
+		t.Errorf("Expected Interpolate() to error out with n=0")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tdigest := uncheckedNew()
+
+	if _, err := tdigest.Normalize(); err == nil {
+		t.Errorf("Expected Normalize() to error out on an empty digest")
+	}
+
+	_ = tdigest.Add(42)
+	_ = tdigest.Add(42)
+	if _, err := tdigest.Normalize(); err == nil {
+		t.Errorf("Expected Normalize() to error out with a zero value range")
+	}
+
+	rnd := rand.New(rand.NewSource(0xCA10))
+	for i := 0; i < 10000; i++ {
+		_ = tdigest.Add(rnd.NormFloat64()*10 + 42)
+	}
+
+	normalized, err := tdigest.Normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if normalized.Quantile(0) != 0 || normalized.Quantile(1) != 1 {
+		t.Errorf("Expected the normalized range to be [0, 1], got [%f, %f]",
+			normalized.Quantile(0), normalized.Quantile(1))
+	}
+
+	if normalized.Count() != tdigest.Count() || normalized.summary.Len() != tdigest.summary.Len() {
+		t.Errorf("Normalize() should preserve the centroids")
+	}
+
+	min, max := tdigest.Quantile(0), tdigest.Quantile(1)
+	for _, q := range []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+		wanted := (tdigest.Quantile(q) - min) / (max - min)
+		if !closeEnough(normalized.Quantile(q), wanted) {
+			t.Errorf("Expected Quantile(%.2f) = %f, got %f", q, wanted, normalized.Quantile(q))
+		}
+	}
+
+	// The original digest is left untouched
+	if tdigest.Quantile(0) != min || tdigest.Quantile(1) != max {
+		t.Errorf("Normalize() should not modify the original digest")
+	}
+}
+
+func TestNewFromCentroids(t *testing.T) {
+	tdigest, err := NewFromCentroids(42, []Centroid{{Mean: 2, Count: 3}, {Mean: 1, Count: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tdigest.Compression() != 42 || tdigest.Count() != 4 || tdigest.summary.Len() != 2 {
+		t.Errorf("Unexpected digest %#v", tdigest)
+	}
+
+	if tdigest.summary.Mean(0) != 1 || tdigest.summary.Count(1) != 3 {
+		t.Errorf("Expected centroids to be sorted. Got %#v", tdigest)
+	}
+
+	if _, err := NewFromCentroids(0, nil); err == nil {
+		t.Errorf("Expected an error with an invalid compression")
+	}
+
+	if _, err := NewFromCentroids(100, []Centroid{{Mean: math.NaN(), Count: 1}}); err == nil {
+		t.Errorf("Expected an error with a NaN mean")
+	}
+
+	if _, err := NewFromCentroids(100, []Centroid{{Mean: 1, Count: 0}}); err == nil {
+		t.Errorf("Expected an error with a zero count")
+	}
+}
+
+func TestIntercentroidDistance(t *testing.T) {
+	tdigest := uncheckedNew()
+
+	if !math.IsNaN(tdigest.IntercentroidDistance()) {
+		t.Errorf("IntercentroidDistance() on an empty digest should return NaN")
+	}
+
+	_ = tdigest.Add(1)
+	if !math.IsNaN(tdigest.IntercentroidDistance()) {
+		t.Errorf("IntercentroidDistance() on a single centroid digest should return NaN")
+	}
+
+	_ = tdigest.Add(2)
+	_ = tdigest.Add(4)
+	if tdigest.IntercentroidDistance() != 1.5 {
+		t.Errorf("Expected IntercentroidDistance() = 1.5, got %f", tdigest.IntercentroidDistance())
+	}
+
+	lastDistance := math.Inf(1)
+	for _, compression := range []float64{10, 50, 100, 500} {
+		rnd := rand.New(rand.NewSource(0xCA10))
+		tdigest = uncheckedNew(Compression(compression))
+		for i := 0; i < 100000; i++ {
+			_ = tdigest.Add(rnd.Float64())
+		}
+		_ = tdigest.Compress()
+
+		distance := tdigest.IntercentroidDistance()
+		if distance >= lastDistance {
+			t.Errorf("Expected a smaller distance for compression=%.0f. Got %f >= %f",
+				compression, distance, lastDistance)
+		}
+		lastDistance = distance
+	}
+}
+
+func TestPrefill(t *testing.T) {
+	tdigest := uncheckedNew()
+
+	// Quantiles of U(0, 100)
+	qs := []float64{0, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 1}
+	values := []float64{0, 10, 25, 50, 75, 90, 99, 100}
+
+	err := tdigest.Prefill(qs, values, 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tdigest.Count() != 10000 {
+		t.Errorf("Expected count to be 10000, got %d", tdigest.Count())
+	}
+
+	if tdigest.summary.Len() != len(qs)-1 {
+		t.Errorf("Expected %d centroids, got %d", len(qs)-1, tdigest.summary.Len())
+	}
+
+	for _, q := range []float64{0.25, 0.5, 0.75, 0.9} {
+		if got := tdigest.Quantile(q); math.Abs(got-100*q) > 5 {
+			t.Errorf("Expected Quantile(%.2f) close to %.0f, got %.4f", q, 100*q, got)
+		}
+	}
+
+	bad := []struct {
+		qs, values []float64
+	}{
+		{[]float64{0, 1}, []float64{0}},
+		{[]float64{0.5}, []float64{1}},
+		{[]float64{0.5, 0.1}, []float64{1, 2}},
+		{[]float64{0.1, 0.5}, []float64{2, 1}},
+		{[]float64{0.1, 1.5}, []float64{1, 2}},
+	}
+	for _, b := range bad {
+		if tdigest.Prefill(b.qs, b.values, 100) == nil {
+			t.Errorf("Expected Prefill(%v, %v) to error out", b.qs, b.values)
+		}
+	}
+}
+
+func TestSetCompression(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	tdigest := uncheckedNew(Compression(100))
+	for i := 0; i < 10000; i++ {
+		_ = tdigest.Add(rng.Float64())
+	}
+
+	before := tdigest.Len()
+	err := tdigest.SetCompression(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tdigest.Compression() != 1000 {
+		t.Errorf("Expected compression 1000, got %f", tdigest.Compression())
+	}
+
+	if tdigest.Len() != before {
+		t.Errorf("Raising the compression changed the number of centroids: %d -> %d", before, tdigest.Len())
+	}
+
+	err = tdigest.SetCompression(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tdigest.Compression() != 10 {
+		t.Errorf("Expected compression 10, got %f", tdigest.Compression())
+	}
+
+	if tdigest.Len() >= before {
+		t.Errorf("Expected lowering the compression to reduce centroids: %d -> %d", before, tdigest.Len())
+	}
+
+	if tdigest.Count() != 10000 {
+		t.Errorf("Expected count to be preserved, got %d", tdigest.Count())
+	}
+
+	for _, c := range []float64{0, 0.5, math.NaN()} {
+		if tdigest.SetCompression(c) == nil {
+			t.Errorf("Expected error for compression=%f", c)
+		}
+	}
+
+	adaptive := uncheckedNew(AdaptiveCompression(10, 100))
+	_ = adaptive.SetCompression(30)
+	for i := 0; i < 10000; i++ {
+		_ = adaptive.Add(rng.Float64())
+	}
+	if adaptive.Compression() != 30 {
+		t.Errorf("Expected SetCompression to disable adaptive compression")
+	}
+}
+
+func TestQuickQuantile(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+
+	empty := uncheckedNew()
+	if !math.IsNaN(empty.QuickQuantile(0.5)) {
+		t.Errorf("Expected NaN for empty digests")
+	}
+
+	small := uncheckedNew()
+	for i := 1; i <= 10; i++ {
+		_ = small.Add(float64(i))
+	}
+	for _, q := range []float64{0, 0.3, 0.99, 1} {
+		if small.QuickQuantile(q) != small.Quantile(q) {
+			t.Errorf("Expected small digests to fallback to Quantile(%.2f)", q)
+		}
+	}
+
+	uniform := uncheckedNew()
+	exponential := uncheckedNew()
+	for i := 0; i < 100000; i++ {
+		_ = uniform.Add(rng.Float64())
+		_ = exponential.Add(rng.ExpFloat64())
+	}
+
+	for _, q := range []float64{0, 0.001, 0.01, 0.5, 0.99, 0.999, 1} {
+		uniformErr := math.Abs(uniform.QuickQuantile(q) - uniform.Quantile(q))
+		expErr := math.Abs(exponential.QuickQuantile(q) - exponential.Quantile(q))
+		t.Logf("q=%.3f uniform error=%.6f exponential error=%.6f", q, uniformErr, expErr)
+
+		// Uniform data is linear, so the estimation is decent everywhere
+		if uniformErr > 0.01 {
+			t.Errorf("QuickQuantile(%.3f) too far from Quantile for uniform data: %f", q, uniformErr)
+		}
+	}
+
+	// On the tails the boundary centroids are used directly
+	for _, q := range []float64{0, 1} {
+		if exponential.QuickQuantile(q) != exponential.Quantile(q) {
+			t.Errorf("Expected QuickQuantile(%.0f) to match Quantile", q)
+		}
+	}
+
+	shouldPanic(func() { uniform.QuickQuantile(-0.1) }, t, "q < 0 should panic")
+	shouldPanic(func() { uniform.QuickQuantile(1.1) }, t, "q > 1 should panic")
+}
+
+func TestQuantiles(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+
+	empty := uncheckedNew()
+	for _, v := range empty.Quantiles([]float64{0.1, 0.9}) {
+		if !math.IsNaN(v) {
+			t.Errorf("Expected NaN for empty digests, got %f", v)
+		}
+	}
+
+	tdigest := uncheckedNew(Compression(1000))
+	for i := 0; i < 100000; i++ {
+		_ = tdigest.Add(rng.NormFloat64())
+	}
+
+	// Unsorted, with duplicates and extremities
+	qs := []float64{0.99, 0.5, 0, 0.999, 0.5, 1, 0.001, 0.25, 0.9}
+	result := tdigest.Quantiles(qs)
+
+	if len(result) != len(qs) {
+		t.Fatalf("Expected %d results, got %d", len(qs), len(result))
+	}
+
+	for i, q := range qs {
+		if result[i] != tdigest.Quantile(q) {
+			t.Errorf("Quantiles()[%d] = %f, but Quantile(%.3f) = %f", i, result[i], q, tdigest.Quantile(q))
+		}
+	}
+
+	if len(tdigest.Quantiles(nil)) != 0 {
+		t.Errorf("Expected empty result for empty input")
+	}
+
+	shouldPanic(func() { tdigest.Quantiles([]float64{0.5, 1.1}) }, t, "q > 1 should panic")
+	shouldPanic(func() { tdigest.Quantiles([]float64{-0.1}) }, t, "q < 0 should panic")
+}
+
+func TestReport(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	qs := []float64{0.5, 0.9, 0.99, 0, 1}
+
+	empty := uncheckedNew().Report(qs)
+	if empty == nil || len(empty) != 0 {
+		t.Errorf("Expected an empty map for empty digests, got %v", empty)
+	}
+
+	tdigest := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = tdigest.Add(rng.NormFloat64())
+	}
+
+	report := tdigest.Report(qs)
+	if len(report) != len(qs) {
+		t.Fatalf("Expected %d entries, got %d", len(qs), len(report))
+	}
+
+	for _, q := range qs {
+		value, ok := report[q]
+		if !ok {
+			t.Errorf("Expected an entry for %.2f", q)
+		} else if value != tdigest.Quantile(q) {
+			t.Errorf("Report()[%.2f] = %f, but Quantile(%.2f) = %f", q, value, q, tdigest.Quantile(q))
+		}
+	}
+
+	shouldPanic(func() { tdigest.Report([]float64{0.5, 1.1}) }, t, "q > 1 should panic")
+	shouldPanic(func() { uncheckedNew().Report([]float64{-0.1}) }, t, "q < 0 should panic")
+}
+
+func TestMinMax(t *testing.T) {
+	tdigest := uncheckedNew()
+	if !math.IsNaN(tdigest.Min()) || !math.IsNaN(tdigest.Max()) {
+		t.Errorf("Expected NaN for empty digests")
+	}
+
+	_ = tdigest.Add(42)
+	if tdigest.Min() != 42 || tdigest.Max() != 42 {
+		t.Errorf("Expected min=max=42, got %f and %f", tdigest.Min(), tdigest.Max())
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	min, max := math.Inf(1), math.Inf(-1)
+	for i := 0; i < 10000; i++ {
+		value := rng.NormFloat64()
+		min = math.Min(min, value)
+		max = math.Max(max, value)
+		_ = tdigest.Add(value)
+	}
+	min = math.Min(min, 42)
+	max = math.Max(max, 42)
+
+	if tdigest.Min() != min {
+		t.Errorf("Expected Min() = %f, got %f", min, tdigest.Min())
+	}
+
+	if tdigest.Max() != max {
+		t.Errorf("Expected Max() = %f, got %f", max, tdigest.Max())
+	}
+}
+
+func TestReset(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	data := make([]float64, 10000)
+	for i := range data {
+		data[i] = rng.NormFloat64()
+	}
+
+	tdigest := uncheckedNew(Compression(50))
+	for _, x := range data[:5000] {
+		_ = tdigest.Add(x)
+	}
+
+	capacity := cap(tdigest.summary.means)
+	tdigest.Reset()
+
+	if tdigest.Count() != 0 || tdigest.Len() != 0 || !math.IsNaN(tdigest.Quantile(0.5)) {
+		t.Fatalf("Expected an empty digest after Reset()")
+	}
+
+	if tdigest.Compression() != 50 || tdigest.rng == nil {
+		t.Errorf("Expected Reset() to preserve the configuration")
+	}
+
+	if cap(tdigest.summary.means) != capacity {
+		t.Errorf("Expected Reset() to preserve the capacity")
+	}
+
+	fresh := uncheckedNew(Compression(50))
+	for _, x := range data[5000:] {
+		_ = tdigest.Add(x)
+		_ = fresh.Add(x)
+	}
+
+	if tdigest.Count() != fresh.Count() {
+		t.Errorf("Expected count %d, got %d", fresh.Count(), tdigest.Count())
+	}
+
+	for _, q := range []float64{0, 0.01, 0.5, 0.99, 1} {
+		if math.Abs(tdigest.Quantile(q)-fresh.Quantile(q)) > 0.05 {
+			t.Errorf("Quantile(%.2f) differs from a fresh digest: %f != %f", q, tdigest.Quantile(q), fresh.Quantile(q))
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		tdigest.Reset()
+		for _, x := range data[:100] {
+			_ = tdigest.Add(x)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations when reusing a reset digest, got %.1f", allocs)
+	}
+}
+
+func TestMergeWithRNG(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+
+	other := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = other.Add(rng.NormFloat64())
+	}
+
+	var results [][]byte
+	for i := 0; i < 2; i++ {
+		// Differently seeded, so regular merges would differ
+		configured := newLocalRNG(int64(i))
+		tdigest := uncheckedNew(RandomNumberGenerator(configured))
+		for j := 0; j < 1000; j++ {
+			_ = tdigest.Add(float64(j) / 1000)
+		}
+
+		err := tdigest.MergeWithRNG(other, newLocalRNG(42))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tdigest.rng != configured {
+			t.Fatalf("Expected the configured RNG to be preserved")
+		}
+
+		if tdigest.Count() != 11000 {
+			t.Errorf("Expected count 11000, got %d", tdigest.Count())
+		}
+
+		serialized, _ := tdigest.AsBytes()
+		results = append(results, serialized)
+	}
+
+	if !bytes.Equal(results[0], results[1]) {
+		t.Errorf("Expected MergeWithRNG to be deterministic")
+	}
+}
+
+func TestLen(t *testing.T) {
+	tdigest := uncheckedNew(Compression(10))
+	if tdigest.Len() != 0 {
+		t.Errorf("Expected empty digests to have no centroids, got %d", tdigest.Len())
+	}
+
+	_ = tdigest.AddWeighted(1, 10)
+	if tdigest.Len() != 1 {
+		t.Errorf("Expected a single centroid, got %d", tdigest.Len())
+	}
+
+	// Adding in order and without compressing makes centroids pile up
+	for i := 0; i < 1000; i++ {
+		_ = tdigest.Add(float64(i))
+	}
+	before := tdigest.Len()
+
+	err := tdigest.Compress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tdigest.Len() != tdigest.summary.Len() || tdigest.Len() >= before {
+		t.Errorf("Expected Compress() to reduce the centroids: %d -> %d", before, tdigest.Len())
+	}
+}
+
+func TestRejectsInfinities(t *testing.T) {
+	tdigest := uncheckedNew()
+
+	for _, value := range []float64{math.Inf(1), math.Inf(-1), math.NaN()} {
+		if tdigest.AddWeighted(value, 1) == nil {
+			t.Errorf("Expected error when adding %f to an empty digest", value)
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		_ = tdigest.Add(float64(i))
+	}
+
+	// Even when they would be merged into an existing centroid
+	for _, value := range []float64{math.Inf(1), math.Inf(-1), math.NaN()} {
+		if tdigest.Add(value) == nil {
+			t.Errorf("Expected error when adding %f", value)
+		}
+	}
+
+	if tdigest.Count() != 1000 || tdigest.Max() != 999 || tdigest.Min() != 0 {
+		t.Errorf("Expected the digest to be unchanged by failed additions")
+	}
+
+	if _, err := NewFromCentroids(100, []Centroid{{Mean: math.Inf(1), Count: 1}}); err == nil {
+		t.Errorf("Expected NewFromCentroids to reject infinities")
+	}
+}
+
+func TestCDFs(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+
+	empty := uncheckedNew()
+	for _, v := range empty.CDFs([]float64{0.1, 0.9}) {
+		if !math.IsNaN(v) {
+			t.Errorf("Expected NaN for empty digests, got %f", v)
+		}
+	}
+
+	tdigest := uncheckedNew(Compression(1000))
+	for i := 0; i < 100000; i++ {
+		_ = tdigest.Add(rng.NormFloat64())
+	}
+
+	// Unsorted, with duplicates, NaN and out of range values
+	values := []float64{2, -1, 0, math.NaN(), 0, -10, 10, 0.5, tdigest.Max(), tdigest.Min(), 1.5}
+	result := tdigest.CDFs(values)
+
+	if len(result) != len(values) {
+		t.Fatalf("Expected %d results, got %d", len(values), len(result))
+	}
+
+	for i, value := range values {
+		expected := tdigest.CDF(value)
+		if result[i] != expected && !(math.IsNaN(result[i]) && math.IsNaN(expected)) {
+			t.Errorf("CDFs()[%d] = %f, but CDF(%f) = %f", i, result[i], value, expected)
+		}
+	}
+
+	if len(tdigest.CDFs(nil)) != 0 {
+		t.Errorf("Expected empty result for empty input")
+	}
+}
+
+func TestIQR(t *testing.T) {
+	if !math.IsNaN(uncheckedNew().IQR()) {
+		t.Errorf("Expected NaN for empty digests")
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	tdigest := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = tdigest.Add(rng.Float64())
+	}
+
+	iqr := tdigest.IQR()
+	if math.Abs(iqr-0.5) > 0.01 {
+		t.Errorf("Expected IQR within 2%% of 0.5, got %f", iqr)
+	}
+
+	if iqr != tdigest.Quantile(0.75)-tdigest.Quantile(0.25) {
+		t.Errorf("Expected IQR to match the difference of the quartiles")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+
+	tdigest := uncheckedNew()
+	if err := tdigest.Validate(); err != nil {
+		t.Errorf("Expected empty digests to be valid: %v", err)
+	}
+
+	for i := 0; i < 10000; i++ {
+		_ = tdigest.Add(rng.NormFloat64())
+	}
+	if err := tdigest.Validate(); err != nil {
+		t.Errorf("Expected digest to be valid: %v", err)
+	}
+
+	corruptions := map[string]func(*TDigest){
+		"compression": func(d *TDigest) { d.compression = 0 },
+		"summary":     func(d *TDigest) { d.summary = nil },
+		"lengths":     func(d *TDigest) { d.summary.counts = d.summary.counts[1:] },
+		"nan":         func(d *TDigest) { d.summary.means[3] = math.NaN() },
+		"inf":         func(d *TDigest) { d.summary.means[0] = math.Inf(-1) },
+		"order":       func(d *TDigest) { d.summary.Swap(1, 2) },
+		"zero count":  func(d *TDigest) { d.count -= d.summary.counts[4]; d.summary.counts[4] = 0 },
+		"count":       func(d *TDigest) { d.count++ },
+		"overflow":    func(d *TDigest) { d.summary.counts[0] = math.MaxUint64 },
+	}
+
+	for name, corrupt := range corruptions {
+		broken := tdigest.Clone()
+		corrupt(broken)
+		if err := broken.Validate(); err == nil {
+			t.Errorf("Expected Validate() to detect the %s corruption", name)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+
+	a := uncheckedNew(Compression(100))
+	for i := 0; i < 1000; i++ {
+		_ = a.Add(rng.Float64())
+	}
+
+	// Same centroids, different configuration
+	b, _ := NewFromCentroids(42, a.Centroids(), LocalRandomNumberGenerator(7))
+	if a.Checksum() != b.Checksum() {
+		t.Errorf("Expected digests with the same centroids to have the same checksum")
+	}
+
+	checksum := a.Checksum()
+	_ = a.Add(0.5)
+	if a.Checksum() == checksum {
+		t.Errorf("Expected the checksum to change after adding a sample")
+	}
+
+	// Only the count differs
+	c, _ := NewFromCentroids(100, []Centroid{{Mean: 1, Count: 2}})
+	d, _ := NewFromCentroids(100, []Centroid{{Mean: 1, Count: 3}})
+	if c.Checksum() == d.Checksum() {
+		t.Errorf("Expected the counts to be part of the checksum")
+	}
+
+	if uncheckedNew().Checksum() != uncheckedNew(Compression(5)).Checksum() {
+		t.Errorf("Expected empty digests to have the same checksum")
+	}
+}
+
+func TestMergeMany(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+
+	var data []float64
+	others := make([]*TDigest, 5)
+	for i := range others {
+		others[i] = uncheckedNew()
+		for j := 0; j < 10000; j++ {
+			// Each digest sees a different slice of the distribution
+			value := rng.Float64() + float64(i)
+			data = append(data, value)
+			_ = others[i].Add(value)
+		}
+	}
+	sort.Float64s(data)
+
+	many := uncheckedNew()
+	err := many.MergeMany(others)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loop := uncheckedNew()
+	for _, other := range others {
+		_ = loop.Merge(other)
+	}
+
+	if many.Count() != loop.Count() || many.Count() != uint64(len(data)) {
+		t.Errorf("Expected count %d, got %d", len(data), many.Count())
+	}
+
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999} {
+		if math.Abs(many.Quantile(q)-loop.Quantile(q)) > 0.02 {
+			t.Errorf("Quantile(%.3f): MergeMany=%f, Merge=%f", q, many.Quantile(q), loop.Quantile(q))
+		}
+		assertDifferenceFromQuantile(data, many, q, 0.02, t)
+	}
+
+	if err := many.MergeMany(nil); err != nil || many.Count() != uint64(len(data)) {
+		t.Errorf("Expected merging nothing to be a no-op")
+	}
+}
+
+func TestNewFromSlice(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+
+	data := make([]float64, 10000)
+	for i := range data {
+		// Plenty of repeated values
+		data[i] = float64(rng.Intn(1000)) / 10
+	}
+	original := append([]float64{}, data...)
+
+	tdigest, err := NewFromSlice(data, Compression(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(data, original) {
+		t.Errorf("Expected NewFromSlice to leave the data untouched")
+	}
+
+	if tdigest.Count() != uint64(len(data)) {
+		t.Errorf("Expected count %d, got %d", len(data), tdigest.Count())
+	}
+
+	sort.Float64s(original)
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		assertDifferenceFromQuantile(original, tdigest, q, 0.5, t)
+	}
+
+	empty, err := NewFromSlice(nil)
+	if err != nil || empty.Count() != 0 {
+		t.Errorf("Expected an empty digest from an empty slice")
+	}
+
+	if _, err := NewFromSlice([]float64{1, math.NaN()}); err == nil {
+		t.Errorf("Expected error for NaN samples")
+	}
+
+	if _, err := NewFromSlice(data, Compression(0)); err == nil {
+		t.Errorf("Expected error for invalid options")
+	}
+}
+
+func TestAddAll(t *testing.T) {
+	tdigest := uncheckedNew()
+
+	err := tdigest.AddAll()
+	if err != nil || tdigest.Count() != 0 {
+		t.Errorf("Expected adding nothing to be a no-op")
+	}
+
+	err = tdigest.AddAll(1, 2, 3, 4, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tdigest.Count() != 5 || tdigest.Quantile(0.5) != 3 {
+		t.Errorf("Expected 5 samples with median 3, got %d and %f", tdigest.Count(), tdigest.Quantile(0.5))
+	}
+
+	data := []float64{6, 7, math.NaN(), 8}
+	err = tdigest.AddAll(data...)
+	if err == nil {
+		t.Fatalf("Expected error when adding NaN")
+	}
+	if !strings.Contains(err.Error(), "sample 2") {
+		t.Errorf("Expected the error to tell the failing index, got %q", err)
+	}
+	if tdigest.Count() != 7 {
+		t.Errorf("Expected the samples before NaN to be added, got count %d", tdigest.Count())
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	stats := uncheckedNew(Compression(42)).Summarize()
+	if stats.Count != 0 || stats.Centroids != 0 || stats.Compression != 42 {
+		t.Errorf("Unexpected stats for an empty digest: %+v", stats)
+	}
+	for _, f := range []float64{stats.Min, stats.Max, stats.Mean, stats.Median, stats.P90, stats.P95, stats.P99, stats.P999} {
+		if !math.IsNaN(f) {
+			t.Errorf("Expected NaN for empty digests: %+v", stats)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	tdigest := uncheckedNew()
+	for i := 0; i < 100000; i++ {
+		_ = tdigest.Add(rng.Float64())
+	}
+
+	stats = tdigest.Summarize()
+	if math.Abs(stats.Median-0.5) > 0.02 {
+		t.Errorf("Expected the median within 0.02 of 0.5, got %f", stats.Median)
+	}
+
+	if math.Abs(stats.Mean-0.5) > 0.02 {
+		t.Errorf("Expected the mean within 0.02 of 0.5, got %f", stats.Mean)
+	}
+
+	if stats.Count != tdigest.Count() || stats.Centroids != tdigest.Len() ||
+		stats.Min != tdigest.Min() || stats.Max != tdigest.Max() ||
+		stats.P99 != tdigest.Quantile(0.99) || stats.P999 != tdigest.Quantile(0.999) {
+		t.Errorf("Stats don't match the digest: %+v", stats)
+	}
+
+	if !(stats.Min <= stats.Median && stats.Median <= stats.P90 && stats.P90 <= stats.P95 &&
+		stats.P95 <= stats.P99 && stats.P99 <= stats.P999 && stats.P999 <= stats.Max) {
+		t.Errorf("Expected ordered quantiles: %+v", stats)
+	}
+}
+
+func TestMean(t *testing.T) {
+	tdigest := uncheckedNew(Compression(10))
+	if !math.IsNaN(tdigest.Mean()) {
+		t.Errorf("Expected NaN for empty digests")
+	}
+
+	for i := 1; i <= 100; i++ {
+		_ = tdigest.Add(float64(i))
+	}
+
+	// Exact even though centroids get merged
+	_ = tdigest.Compress()
+	if tdigest.Len() >= 100 || !closeEnough(tdigest.Mean(), 50.5) {
+		t.Errorf("Expected mean 50.5 with merged centroids, got %f (%d centroids)", tdigest.Mean(), tdigest.Len())
+	}
+
+	_ = tdigest.AddWeighted(1000, 100)
+	if !closeEnough(tdigest.Mean(), (5050+100000)/200.0) {
+		t.Errorf("Expected the weights to be taken into account, got %f", tdigest.Mean())
+	}
+}
+
+func TestVariance(t *testing.T) {
+	tdigest := uncheckedNew()
+	_ = tdigest.Add(1)
+	if !math.IsNaN(tdigest.Variance()) || !math.IsNaN(tdigest.StdDev()) {
+		t.Errorf("Expected NaN for less than two samples")
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	tdigest = uncheckedNew()
+	data := make([]float64, 100000)
+	mean := 0.0
+	for i := range data {
+		data[i] = 10 + 3*rng.NormFloat64()
+		mean += data[i]
+		_ = tdigest.Add(data[i])
+	}
+	mean /= float64(len(data))
+
+	variance := 0.0
+	for _, x := range data {
+		variance += (x - mean) * (x - mean)
+	}
+	variance /= float64(len(data))
+
+	if math.Abs(tdigest.Variance()-variance) > 0.05*variance {
+		t.Errorf("Expected variance within 5%% of %f, got %f", variance, tdigest.Variance())
+	}
+
+	if tdigest.StdDev() != math.Sqrt(tdigest.Variance()) {
+		t.Errorf("Expected StdDev to be the square root of the variance")
+	}
+}
+
+func TestSkewnessKurtosis(t *testing.T) {
+	tdigest := uncheckedNew()
+	_ = tdigest.AddWeighted(1, 10)
+	if !math.IsNaN(tdigest.Skewness()) || !math.IsNaN(tdigest.Kurtosis()) {
+		t.Errorf("Expected NaN for less than two centroids")
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	exponential := uncheckedNew()
+	normal := uncheckedNew()
+	for i := 0; i < 100000; i++ {
+		_ = exponential.Add(rng.ExpFloat64())
+		_ = normal.Add(rng.NormFloat64())
+	}
+
+	// Exponential: skewness 2, kurtosis 9
+	if skewness := exponential.Skewness(); math.Abs(skewness-2) > 0.15*2 {
+		t.Errorf("Expected skewness within 15%% of 2, got %f", skewness)
+	}
+	if kurtosis := exponential.Kurtosis(); math.Abs(kurtosis-9) > 0.15*9 {
+		t.Errorf("Expected kurtosis within 15%% of 9, got %f", kurtosis)
+	}
+
+	// Normal: skewness 0, kurtosis 3
+	if skewness := normal.Skewness(); math.Abs(skewness) > 0.05 {
+		t.Errorf("Expected skewness close to 0, got %f", skewness)
+	}
+	if kurtosis := normal.Kurtosis(); math.Abs(kurtosis-3) > 0.15*3 {
+		t.Errorf("Expected kurtosis within 15%% of 3, got %f", kurtosis)
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	tdigest := uncheckedNew()
+	if !math.IsNaN(tdigest.Entropy()) {
+		t.Errorf("Expected NaN for empty digests")
+	}
+
+	_ = tdigest.AddWeighted(5, 100)
+	if tdigest.Entropy() != 0 {
+		t.Errorf("Expected 0 for a single centroid, got %f", tdigest.Entropy())
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+
+	// 1000 equally likely values
+	uniform := uncheckedNew()
+	for i := 0; i < 100000; i++ {
+		_ = uniform.Add(float64(rng.Intn(1000)))
+	}
+	if entropy := uniform.Entropy(); math.Abs(entropy-math.Log(1000)) > 0.05*math.Log(1000) {
+		t.Errorf("Expected entropy within 5%% of log(1000) = %f, got %f", math.Log(1000), entropy)
+	}
+
+	// Constant: every centroid sits on the same value
+	point := uncheckedNew()
+	for i := 0; i < 1000; i++ {
+		_ = point.Add(42)
+	}
+	if point.Entropy() != 0 {
+		t.Errorf("Expected 0 for a constant distribution, got %f", point.Entropy())
+	}
+
+	// Concentrated within a unit: U(10, 11), whose entropy is log(1) = 0
+	constant := uncheckedNew()
+	for i := 0; i < 100000; i++ {
+		_ = constant.Add(10 + rng.Float64())
+	}
+	if entropy := constant.Entropy(); math.Abs(entropy) > 0.05 {
+		t.Errorf("Expected entropy close to 0, got %f", entropy)
+	}
+
+	// Normal: 0.5 * log(2 * pi * e * variance)
+	normal := uncheckedNew()
+	for i := 0; i < 100000; i++ {
+		_ = normal.Add(3 * rng.NormFloat64())
+	}
+	wanted := 0.5 * math.Log(2*math.Pi*math.E*9)
+	if entropy := normal.Entropy(); math.Abs(entropy-wanted) > 0.05*wanted {
+		t.Errorf("Expected entropy within 5%% of %f, got %f", wanted, entropy)
+	}
+}
+
+func TestMAD(t *testing.T) {
+	tdigest := uncheckedNew()
+	if !math.IsNaN(tdigest.MAD()) {
+		t.Errorf("Expected NaN for empty digests")
+	}
+
+	_ = tdigest.AddWeighted(5, 10)
+	if tdigest.MAD() != 0 {
+		t.Errorf("Expected 0 for a single value, got %f", tdigest.MAD())
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	tdigest = uncheckedNew()
+	for i := 0; i < 100000; i++ {
+		_ = tdigest.Add(rng.NormFloat64())
+	}
+
+	if mad := tdigest.MAD(); math.Abs(mad-0.6745) > 0.05*0.6745 {
+		t.Errorf("Expected MAD within 5%% of 0.6745, got %f", mad)
+	}
+
+	// Outliers barely move it
+	for i := 0; i < 100; i++ {
+		_ = tdigest.Add(1e6)
+	}
+	if mad := tdigest.MAD(); math.Abs(mad-0.6745) > 0.05*0.6745 {
+		t.Errorf("Expected MAD to resist outliers, got %f", mad)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	tdigest := uncheckedNew(Compression(50))
+	info := tdigest.Inspect()
+	if info.Count != 0 || info.Centroids != 0 || info.Compression != 50 || info.UncompressedRatio != 0 {
+		t.Errorf("Unexpected info for an empty digest: %+v", info)
+	}
+
+	checkRatio := func(operation string) {
+		info := tdigest.Inspect()
+		if info.UncompressedRatio <= 0 || info.UncompressedRatio >= 1 {
+			t.Errorf("Expected a ratio in (0, 1) after %s, got %f", operation, info.UncompressedRatio)
+		}
+		if info.Count != tdigest.Count() || info.Centroids != tdigest.Len() {
+			t.Errorf("Expected info to match the digest after %s: %+v", operation, info)
+		}
+		if info.MemoryEstimate < 16*info.Centroids {
+			t.Errorf("Expected at least 16 bytes per centroid after %s, got %d", operation, info.MemoryEstimate)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	for i := 0; i < 100000; i++ {
+		_ = tdigest.Add(rng.NormFloat64())
+		if i%10000 == 0 {
+			checkRatio("adding")
+		}
+	}
+
+	other := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = other.Add(float64(i))
+	}
+	_ = tdigest.Merge(other)
+	checkRatio("merging")
+
+	_ = tdigest.SetCompression(20)
+	checkRatio("lowering the compression")
+
+	_ = tdigest.Compress()
+	checkRatio("compressing")
+
+	if info := tdigest.Freeze().Inspect(); !info.IsFrozen || info.IsLazyCompress {
+		t.Errorf("Expected a frozen, eager digest: %+v", info)
+	}
+
+	// Pending compressions go past the threshold
+	lazy := uncheckedNew(Compression(10), LazyCompress())
+	for i := 0; i < 1000; i++ {
+		_ = lazy.Add(float64(i))
+	}
+	if info := lazy.Inspect(); !info.IsLazyCompress || info.UncompressedRatio != 5 {
+		t.Errorf("Expected a lazy digest with a ratio of 1000/200, got %+v", info)
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	fill := func(digest *TDigest, n int) *TDigest {
+		rng := rand.New(rand.NewSource(0xCA10))
+		for i := 0; i < n; i++ {
+			_ = digest.Add(rng.NormFloat64())
+		}
+		return digest
+	}
+
+	// Grows along with the compression
+	last := fill(uncheckedNew(Compression(100)), 100000).MemoryUsage()
+	for _, compression := range []float64{200, 400, 800} {
+		usage := fill(uncheckedNew(Compression(compression)), 100000).MemoryUsage()
+		if ratio := float64(usage) / float64(last); ratio < 1.2 || ratio > 3 {
+			t.Errorf("Expected usage to roughly double with compression %.0f, got %d -> %d", compression, last, usage)
+		}
+		last = usage
+	}
+
+	if info := uncheckedNew().Inspect(); info.MemoryEstimate != uncheckedNew().MemoryUsage() {
+		t.Errorf("Expected Inspect() to report MemoryUsage(), got %d", info.MemoryEstimate)
+	}
+
+	// Matches what gets allocated, with a shared (zero-sized) RNG
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	digests := make([]*TDigest, 100)
+	estimate := 0
+	for i := range digests {
+		digests[i] = fill(uncheckedNew(GlobalRandomNumberGenerator()), 10000)
+		estimate += digests[i].MemoryUsage()
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(digests)
+
+	allocated := int(after.HeapAlloc) - int(before.HeapAlloc)
+	if math.Abs(float64(allocated-estimate)) > 0.1*float64(allocated) {
+		t.Errorf("Expected the estimation to be within 10%% of the %d allocated bytes, got %d", allocated, estimate)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tdigest := uncheckedNew()
+	if !math.IsNaN(tdigest.Percentile(50)) {
+		t.Errorf("Expected NaN for empty digests")
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	for i := 0; i < 10000; i++ {
+		_ = tdigest.Add(rng.Float64())
+	}
+
+	p := 99.9
+	if tdigest.Percentile(p) != tdigest.Quantile(p/100) {
+		t.Errorf("Expected Percentile(%.1f) to match Quantile(%.3f)", p, p/100)
+	}
+
+	ps := []float64{50, 0, p, 100}
+	many := tdigest.PercentileMany(ps)
+	for i, p := range ps {
+		if many[i] != tdigest.Percentile(p) {
+			t.Errorf("PercentileMany()[%d] = %f, but Percentile(%f) = %f", i, many[i], p, tdigest.Percentile(p))
+		}
+	}
+
+	shouldPanic(func() { tdigest.Percentile(-1) }, t, "p < 0 should panic")
+	shouldPanic(func() { tdigest.Percentile(100.1) }, t, "p > 100 should panic")
+	shouldPanic(func() { tdigest.PercentileMany([]float64{50, 101}) }, t, "p > 100 should panic")
+}
+
+func TestScale(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	original := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = original.Add(rng.ExpFloat64())
+	}
+
+	for _, factor := range []float64{1e6, 1e-3, -2} {
+		scaled := original.Clone()
+		err := scaled.Scale(factor)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := scaled.Validate(); err != nil {
+			t.Fatalf("Scale(%g) broke the digest: %v", factor, err)
+		}
+
+		for _, q := range []float64{0, 0.1, 0.5, 0.9, 1} {
+			mirrored := q
+			if factor < 0 {
+				mirrored = 1 - q
+			}
+			expected := original.Quantile(q) * factor
+			if math.Abs(scaled.Quantile(mirrored)-expected) > 1e-9*math.Abs(expected) {
+				t.Errorf("Scale(%g): Quantile(%.1f) = %f, expected %f", factor, mirrored, scaled.Quantile(mirrored), expected)
+			}
+		}
+	}
+
+	for _, factor := range []float64{0, math.NaN(), math.Inf(1), math.MaxFloat64} {
+		scaled := original.Clone()
+		if scaled.Scale(factor) == nil {
+			t.Errorf("Expected error for factor %g", factor)
+		}
+		if scaled.Checksum() != original.Checksum() {
+			t.Errorf("Expected the digest to be untouched after failing to scale by %g", factor)
+		}
+	}
+
+	if err := uncheckedNew().Scale(2); err != nil {
+		t.Errorf("Expected scaling an empty digest to work, got %v", err)
+	}
+}
+
+func TestShift(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	original := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = original.Add(rng.NormFloat64())
+	}
+
+	shifted := original.Clone()
+	if err := shifted.Shift(1000); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []float64{0, 0.1, 0.5, 0.9, 1} {
+		expected := original.Quantile(q) + 1000
+		if math.Abs(shifted.Quantile(q)-expected) > 1e-9 {
+			t.Errorf("Quantile(%.1f) = %f, expected %f", q, shifted.Quantile(q), expected)
+		}
+	}
+
+	if err := shifted.Shift(-1000); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []float64{0, 0.1, 0.5, 0.9, 1} {
+		if math.Abs(shifted.Quantile(q)-original.Quantile(q)) > 1e-9 {
+			t.Errorf("Expected the round-trip to preserve Quantile(%.1f): %f != %f", q, shifted.Quantile(q), original.Quantile(q))
+		}
+	}
+
+	for _, delta := range []float64{math.NaN(), math.Inf(-1)} {
+		if shifted.Shift(delta) == nil {
+			t.Errorf("Expected error for delta %g", delta)
+		}
+	}
+
+	huge := uncheckedNew()
+	_ = huge.Add(math.MaxFloat64)
+	if huge.Shift(math.MaxFloat64) == nil || huge.Max() != math.MaxFloat64 {
+		t.Errorf("Expected an overflowing shift to fail and leave the digest untouched")
+	}
+}
+
+func TestPartition(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	tdigest := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = tdigest.Add(rng.NormFloat64())
+	}
+
+	property := func(pivot float64) bool {
+		lo, hi, err := tdigest.Partition(pivot)
+		if err != nil {
+			return false
+		}
+
+		if lo.Validate() != nil || hi.Validate() != nil {
+			return false
+		}
+
+		if lo.Len() > 0 && lo.Max() >= pivot || hi.Len() > 0 && hi.Min() < pivot {
+			return false
+		}
+
+		return lo.Count()+hi.Count() == tdigest.Count() && lo.Len()+hi.Len() == tdigest.Len()
+	}
+
+	config := &quick.Config{
+		Rand: rng,
+		Values: func(args []reflect.Value, rng *rand.Rand) {
+			// Mostly within the range of the samples
+			args[0] = reflect.ValueOf(rng.NormFloat64() * 2)
+		},
+	}
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+
+	for _, pivot := range []float64{math.Inf(-1), tdigest.Min(), tdigest.Max(), math.Inf(1)} {
+		if !property(pivot) {
+			t.Errorf("Partition(%f) broke the invariants", pivot)
+		}
+	}
+
+	// Centroids exactly at the pivot go to the right
+	lo, hi, _ := tdigest.Partition(tdigest.Min())
+	if lo.Count() != 0 || hi.Count() != tdigest.Count() {
+		t.Errorf("Expected everything to be at or above the minimum")
+	}
+
+	_ = lo.Add(-100)
+	if tdigest.Min() == -100 {
+		t.Errorf("Expected the partitions to be independent from the original")
+	}
+
+	if _, _, err := tdigest.Partition(math.NaN()); err == nil {
+		t.Errorf("Expected error for a NaN pivot")
+	}
+}
+
+func TestMustNew(t *testing.T) {
+	tdigest := MustNew(Compression(42))
+	if tdigest.Compression() != 42 || tdigest.Add(1) != nil {
+		t.Errorf("Expected MustNew to create a usable digest")
+	}
+
+	shouldPanic(func() {
+		MustNew(Compression(0))
+	}, t, "MustNew with invalid options should panic!")
+}
+
+func TestHistogram(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	tdigest := uncheckedNew()
+	for i := 0; i < 100000; i++ {
+		_ = tdigest.Add(rng.Float64() * 100)
+	}
+
+	buckets := []float64{-1, 10, 25, 50, 75, 99, 200}
+	expected := []uint64{0, 10000, 25000, 50000, 75000, 99000, 100000, 100000}
+
+	histogram := tdigest.Histogram(buckets)
+	if len(histogram) != len(expected) {
+		t.Fatalf("Expected %d buckets, got %d", len(expected), len(histogram))
+	}
+
+	for i, count := range histogram {
+		if math.Abs(float64(count)-float64(expected[i])) > 0.02*float64(expected[i]) {
+			t.Errorf("Expected bucket %d to hold ~%d samples, got %d", i, expected[i], count)
+		}
+	}
+
+	empty := uncheckedNew().Histogram(buckets)
+	if !reflect.DeepEqual(empty, make([]uint64, len(buckets)+1)) {
+		t.Errorf("Expected all buckets of an empty digest to be zero, got %v", empty)
+	}
+
+	shouldPanic(func() {
+		tdigest.Histogram(nil)
+	}, t, "Histogram with no buckets should panic!")
+
+	shouldPanic(func() {
+		tdigest.Histogram([]float64{1, 3, 2})
+	}, t, "Histogram with unsorted buckets should panic!")
+
+	shouldPanic(func() {
+		tdigest.Histogram([]float64{1, math.NaN()})
+	}, t, "Histogram with NaN buckets should panic!")
+}
+
+func TestPDF(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	tdigest := uncheckedNew()
+	for i := 0; i < 100000; i++ {
+		mode := -5.0
+		if i%2 == 0 {
+			mode = 5
+		}
+		_ = tdigest.Add(mode + rng.NormFloat64())
+	}
+
+	centers, densities := tdigest.PDF(50)
+	if len(centers) != 50 || len(densities) != 50 {
+		t.Fatalf("Expected 50 bins, got %d centers and %d densities", len(centers), len(densities))
+	}
+
+	argmax := func(from, to int) int {
+		best := from
+		for i := from; i < to; i++ {
+			if densities[i] > densities[best] {
+				best = i
+			}
+		}
+		return best
+	}
+
+	left, right := argmax(0, 25), argmax(25, 50)
+	if math.Abs(centers[left]+5) > 0.5 || math.Abs(centers[right]-5) > 0.5 {
+		t.Errorf("Expected density peaks close to -5 and 5, got %f and %f", centers[left], centers[right])
+	}
+
+	// Each mode is a N(0, 1) holding half of the samples
+	peak := 0.5 / math.Sqrt(2*math.Pi)
+	for _, i := range []int{left, right} {
+		if math.Abs(densities[i]-peak) > 0.1*peak {
+			t.Errorf("Expected a peak density close to %f, got %f", peak, densities[i])
+		}
+	}
+
+	valley := densities[argmax(24, 26)]
+	if valley > peak/10 {
+		t.Errorf("Expected a low density between the peaks, got %f", valley)
+	}
+
+	total := 0.0
+	for i, density := range densities {
+		if density < 0 {
+			t.Errorf("Expected non-negative densities, got %f at %f", density, centers[i])
+		}
+		total += density * (centers[1] - centers[0])
+	}
+	if math.Abs(total-1) > 0.01 {
+		t.Errorf("Expected the densities to integrate to ~1, got %f", total)
+	}
+
+	if centers, densities := uncheckedNew().PDF(10); centers != nil || densities != nil {
+		t.Errorf("Expected nil slices for an empty digest")
+	}
+
+	single := uncheckedNew()
+	_ = single.AddWeighted(42, 10)
+	centers, densities = single.PDF(10)
+	if len(centers) != 1 || centers[0] != 42 || !math.IsInf(densities[0], 1) {
+		t.Errorf("Expected a point mass at 42, got %v %v", centers, densities)
+	}
+
+	shouldPanic(func() {
+		tdigest.PDF(0)
+	}, t, "PDF with no bins should panic!")
+}
+
+func TestRank(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	tdigest := uncheckedNew()
+	for i := 0; i < 100000; i++ {
+		_ = tdigest.Add(rng.Float64())
+	}
+
+	for _, q := range []float64{0.1, 0.5, 0.9, 0.99} {
+		expected := q * float64(tdigest.Count())
+		rank := tdigest.Rank(tdigest.Quantile(q))
+		if math.Abs(float64(rank)-expected) > 0.01*expected {
+			t.Errorf("Expected Rank(Quantile(%.2f)) close to %.0f, got %d", q, expected, rank)
+		}
+	}
+
+	if tdigest.Rank(-1) != 0 || tdigest.Rank(2) != tdigest.Count() {
+		t.Errorf("Expected ranks to be bounded by 0 and Count()")
+	}
+
+	// Fractional ranks are truncated
+	small := uncheckedNew()
+	for i := 0; i < 10; i++ {
+		_ = small.Add(float64(i % 3))
+	}
+	for _, x := range []float64{0.25, 1.75} {
+		rank := small.CDF(x) * float64(small.Count())
+		if rank == math.Trunc(rank) {
+			t.Fatalf("Expected a fractional rank for %.2f, got %f", x, rank)
+		}
+		if small.Rank(x) != uint64(math.Trunc(rank)) {
+			t.Errorf("Expected Rank(%.2f) = %d, got %d", x, uint64(math.Trunc(rank)), small.Rank(x))
+		}
+	}
+
+	if uncheckedNew().Rank(0.5) != 0 {
+		t.Errorf("Expected rank 0 for an empty digest")
+	}
+}
+
+func TestBootstrapQuantile(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	tdigest := uncheckedNew()
+	for i := 0; i < 100000; i++ {
+		_ = tdigest.Add(rng.NormFloat64())
+	}
+
+	// True quantiles of N(0, 1)
+	expected := map[float64]float64{0.1: -1.2816, 0.5: 0, 0.9: 1.2816}
+
+	covered, total := 0, 0
+	for trial := 0; trial < 20; trial++ {
+		for q, quantile := range expected {
+			estimate, stderr := tdigest.BootstrapQuantile(q, 10000, rng)
+			if !(stderr > 0) || stderr > 0.05 {
+				t.Errorf("Unexpected standard error for q=%.1f: %f", q, stderr)
+			}
+			if math.Abs(estimate-quantile) <= 1.96*stderr {
+				covered++
+			}
+			total++
+		}
+	}
+
+	// Allow for some slack on top of the nominal 95%
+	if coverage := float64(covered) / float64(total); coverage < 0.85 {
+		t.Errorf("Expected the 95%% confidence intervals to cover the true quantile, got %.2f coverage", coverage)
+	}
+
+	// n samples are drawn and the standard error matches the one of the
+	// median of n normal samples, sqrt(pi/2n), rather than the one of a
+	// single batch of n/10 samples
+	counting := &countingRNG{r: rng}
+	var stderrs float64
+	for trial := 0; trial < 20; trial++ {
+		_, stderr := tdigest.BootstrapQuantile(0.5, 10000, counting)
+		stderrs += stderr / 20
+	}
+	if counting.calls != 20*10000 {
+		t.Errorf("Expected 10000 draws per call, got %d in total", counting.calls)
+	}
+	if wanted := math.Sqrt(math.Pi / 20000); math.Abs(stderrs-wanted) > 0.3*wanted {
+		t.Errorf("Expected the standard error to be close to %f, got %f", wanted, stderrs)
+	}
+
+	// More samples mean narrower intervals
+	_, small := tdigest.BootstrapQuantile(0.5, 1000, rng)
+	_, large := tdigest.BootstrapQuantile(0.5, 100000, rng)
+	if small <= large {
+		t.Errorf("Expected the standard error to shrink with n, got %f and %f", small, large)
+	}
+
+	for _, n := range []int{0, 19} {
+		if estimate, stderr := tdigest.BootstrapQuantile(0.5, n, rng); !math.IsNaN(estimate) || !math.IsNaN(stderr) {
+			t.Errorf("Expected NaN for n=%d", n)
+		}
+	}
+
+	if estimate, _ := uncheckedNew().BootstrapQuantile(0.5, 1000, rng); !math.IsNaN(estimate) {
+		t.Errorf("Expected NaN for an empty digest")
+	}
+
+	shouldPanic(func() {
+		tdigest.BootstrapQuantile(1.5, 1000, rng)
+	}, t, "BootstrapQuantile > 1 should panic!")
+}
+
+func TestFreeze(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	tdigest := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = tdigest.Add(rng.Float64())
+	}
+
+	frozen := tdigest.Freeze()
+	if frozen != tdigest {
+		t.Errorf("Expected Freeze to return the receiver")
+	}
+
+	checksum := tdigest.Checksum()
+	other := uncheckedNew()
+	_ = other.Add(42)
+
+	mutations := map[string]func() error{
+		"Add":              func() error { return tdigest.Add(1) },
+		"AddWeighted":      func() error { return tdigest.AddWeighted(1, 10) },
+		"Merge":            func() error { return tdigest.Merge(other) },
+		"MergeDestructive": func() error { return tdigest.MergeDestructive(other.Clone()) },
+		"Compress":         func() error { return tdigest.Compress() },
+		"SetCompression":   func() error { return tdigest.SetCompression(10) },
+		"Scale":            func() error { return tdigest.Scale(2) },
+		"Shift":            func() error { return tdigest.Shift(2) },
+		"FlushTo":          func() error { return tdigest.FlushTo(uncheckedNew()) },
+		"FromBytes": func() error {
+			data, _ := other.AsBytes()
+			return tdigest.FromBytes(data)
+		},
+		"UnmarshalJSON": func() error { return tdigest.UnmarshalJSON([]byte(`{"compression":100,"count":0}`)) },
+	}
+
+	for name, mutate := range mutations {
+		if err := mutate(); !errors.Is(err, ErrFrozen) {
+			t.Errorf("Expected %s to fail with ErrFrozen, got %v", name, err)
+		}
+	}
+
+	if err := other.MergeDestructive(tdigest); !errors.Is(err, ErrFrozen) {
+		t.Errorf("Expected MergeDestructive to refuse destroying a frozen digest, got %v", err)
+	}
+
+	if tdigest.Checksum() != checksum || tdigest.Count() != 10000 {
+		t.Errorf("Expected the frozen digest to be left untouched")
+	}
+
+	// Queries work normally
+	if q := tdigest.Quantile(0.5); math.Abs(q-0.5) > 0.01 {
+		t.Errorf("Expected the median close to 0.5, got %f", q)
+	}
+	if cdf := tdigest.CDF(0.5); math.Abs(cdf-0.5) > 0.01 {
+		t.Errorf("Expected CDF(0.5) close to 0.5, got %f", cdf)
+	}
+	if _, err := tdigest.AsBytes(); err != nil {
+		t.Errorf("Expected AsBytes to work, got %v", err)
+	}
+	if err := other.Merge(tdigest); err != nil {
+		t.Errorf("Expected frozen digests to be mergeable into others, got %v", err)
+	}
+
+	clone := tdigest.Clone()
+	if err := clone.Add(1); err != nil {
+		t.Errorf("Expected clones of frozen digests to be mutable, got %v", err)
+	}
+
+	tdigest.Reset()
+	if err := tdigest.Add(1); err != nil {
+		t.Errorf("Expected Reset to unfreeze the digest, got %v", err)
+	}
+}
+
+func TestTruncatedMean(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	tdigest := uncheckedNew()
+	bulk := 0.0
+	for i := 0; i < 10000; i++ {
+		value := 100 + rng.NormFloat64()*10
+		bulk += value
+		_ = tdigest.Add(value)
+	}
+	bulk /= 10000
+
+	// A few huge outliers drag the mean away
+	for i := 0; i < 50; i++ {
+		_ = tdigest.Add(1e6)
+	}
+
+	mean := tdigest.Mean()
+	truncated := tdigest.TruncatedMean(0.05, 0.95)
+	if math.Abs(truncated-bulk) > 1 {
+		t.Errorf("Expected the truncated mean close to %f, got %f", bulk, truncated)
+	}
+	if math.Abs(mean-bulk) < 100*math.Abs(truncated-bulk) {
+		t.Errorf("Expected the truncated mean (%f) to be much closer to %f than the mean (%f)", truncated, bulk, mean)
+	}
+
+	if truncated != tdigest.TrimmedMean(0.05, 0.95) {
+		t.Errorf("Expected TruncatedMean to match TrimmedMean")
+	}
+
+	for _, got := range []float64{
+		uncheckedNew().TruncatedMean(0.05, 0.95),
+		tdigest.TruncatedMean(0.5, 0.5),
+		tdigest.TruncatedMean(0.9, 0.1),
+		tdigest.TruncatedMean(math.NaN(), 0.5),
+	} {
+		if !math.IsNaN(got) {
+			t.Errorf("Expected NaN, got %f", got)
+		}
+	}
+
+	shouldPanic(func() {
+		tdigest.TruncatedMean(-0.1, 0.5)
+	}, t, "TruncatedMean < 0 should panic!")
+}
+
+func TestEqual(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	a := uncheckedNew()
+	for i := 0; i < 10000; i++ {
+		_ = a.Add(rng.NormFloat64())
+	}
+
+	b := a.Clone()
+	if !a.Equal(b) || !b.Equal(a) {
+		t.Errorf("Expected a digest to be equal to its clone")
+	}
+
+	_ = b.Add(0)
+	if a.Equal(b) {
+		t.Errorf("Expected digests with different samples to differ")
+	}
+
+	c := a.Clone()
+	c.summary.means[0] = math.Nextafter(c.summary.means[0], math.Inf(-1))
+	if a.Equal(c) {
+		t.Errorf("Expected means to be compared exactly")
+	}
+
+	d := a.Clone()
+	d.compression = 42
+	if a.Equal(d) {
+		t.Errorf("Expected digests with different compressions to differ")
+	}
+
+	var nilDigest *TDigest
+	if !nilDigest.Equal(nil) || nilDigest.Equal(a) || a.Equal(nil) {
+		t.Errorf("Expected only two nil digests to be equal")
+	}
+
+	if !uncheckedNew().Equal(uncheckedNew()) {
+		t.Errorf("Expected empty digests to be equal")
+	}
+}
+
+func TestNewFromMap(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	data := make([]float64, 100000)
+	counts := make(map[float64]uint64)
+	for i := range data {
+		data[i] = float64(rng.Intn(1000))
+		counts[data[i]]++
+	}
+
+	digest, err := NewFromMap(counts, Compression(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digest.Count() != uint64(len(data)) || digest.Compression() != 50 {
+		t.Errorf("Unexpected digest: count=%d compression=%f", digest.Count(), digest.Compression())
+	}
+
+	sort.Float64s(data)
+	for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.99} {
+		assertDifferenceFromQuantile(data, digest, q, 5, t)
+	}
+
+	// Doesn't depend on the map iteration order
+	again, _ := NewFromMap(counts, Compression(50))
+	if !digest.Equal(again) {
+		t.Errorf("Expected NewFromMap to be reproducible")
+	}
+
+	empty, err := NewFromMap(nil)
+	if err != nil || empty.Count() != 0 {
+		t.Errorf("Expected an empty digest from an empty map, got %v", err)
+	}
+
+	invalid := []map[float64]uint64{
+		{1: 1, math.NaN(): 1},
+		{math.Inf(1): 1},
+		{1: 0},
+	}
+	for _, data := range invalid {
+		if _, err := NewFromMap(data); err == nil {
+			t.Errorf("Expected error for %v", data)
+		}
+	}
+
+	if _, err := NewFromMap(counts, Compression(0)); err == nil {
+		t.Errorf("Expected error for invalid options")
+	}
+}
+
+var compressions = []float64{1, 10, 20, 30, 50, 100}
+
+func BenchmarkTDigestAddOnce(b *testing.B) {
+	for _, compression := range compressions {
+		compression := compression
+		b.Run(fmt.Sprintf("compression=%.0f", compression), func(b *testing.B) {
+			benchmarkAddOnce(b, compression)
+		})
+	}
+}
+
+func benchmarkAddOnce(b *testing.B, compression float64) {
+	t := uncheckedNew(Compression(compression))
+
+	data := make([]float64, b.N)
+	for n := 0; n < b.N; n++ {
+		data[n] = rand.Float64()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		err := t.Add(data[n])
+		if err != nil {
+			b.Error(err)
+		}
+	}
+	b.StopTimer()
+}
+
+func BenchmarkTDigestAddMulti(b *testing.B) {
+	for _, compression := range compressions {
+		compression := compression
+		for _, n := range []int{10, 100, 1000, 10000} {
+			n := n
+			name := fmt.Sprintf("compression=%.0f n=%d", compression, n)
+			b.Run(name, func(b *testing.B) {
+				benchmarkAddMulti(b, compression, n)
+			})
+		}
+	}
+}
+
+func benchmarkAddMulti(b *testing.B, compression float64, times int) {
+	data := make([]float64, times)
+	for i := 0; i < times; i++ {
+		data[i] = rand.Float64()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		t := uncheckedNew(Compression(compression))
+		for i := 0; i < times; i++ {
+			err := t.AddWeighted(data[i], 1)
+			if err != nil {
+				b.Error(err)
+			}
+		}
+	}
+	b.StopTimer()
+}
+
+func BenchmarkTDigestMerge(b *testing.B) {
+	for _, compression := range compressions {
+		compression := compression
+		for _, n := range []int{1, 10, 100} {
+			name := fmt.Sprintf("compression=%.0f n=%d", compression, n)
+			b.Run(name, func(b *testing.B) {
+				benchmarkMerge(b, compression, n)
+			})
+		}
+	}
+}
+
+func benchmarkMerge(b *testing.B, compression float64, times int) {
+	ts := make([]*TDigest, times)
+	for i := 0; i < times; i++ {
+		ts[i] = randomTDigest(compression)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		dst := uncheckedNew(Compression(compression))
+
+		for i := 0; i < times; i++ {
+			err := dst.Merge(ts[i])
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		err := dst.Compress()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func randomTDigest(compression float64) *TDigest {
+	t := uncheckedNew(Compression(compression))
+	n := 20 * int(compression)
+	for i := 0; i < n; i++ {
+		err := t.Add(rand.Float64())
+		if err != nil {
+			panic(err)
+		}
+	}
+	return t
+}
+
+// Pathological ordered-input case.
+func BenchmarkAddOrdered(b *testing.B) {
+	t, _ := New(Compression(100))
+
+	for n := 0; n < b.N; n++ {
+		err := t.Add(float64(n))
+		if err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func BenchmarkMerge(b *testing.B) {
+	b.ReportAllocs()
+
+	t, _ := New(Compression(100))
+	for n := 0; n < 1000; n++ {
+		t.AddWeighted(rand.Float64(), uint64(rand.Intn(100)))
+	}
+
+	dest, _ := New(Compression(100))
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		dest.Merge(t)
+	}
+}
+
+func BenchmarkMergeDestructive(b *testing.B) {
+	b.ReportAllocs()
+
+	t, _ := New(Compression(100))
+	for n := 0; n < 1000; n++ {
+		t.AddWeighted(rand.Float64(), uint64(rand.Intn(100)))
+	}
+
+	dest, _ := New(Compression(100))
+
+	b.ResetTimer()
+
+	// After the first iteration, t's summary is scrambled, which means it's
 	// mostly useless, but we can still merge it.
 	for n := 0; n < b.N; n++ {
 		dest.MergeDestructive(t)
 	}
 }
+
+var benchmarkQuantiles = []float64{0.5, 0.9, 0.95, 0.99, 0.999}
+
+func BenchmarkQuantileLoop(b *testing.B) {
+	t, _ := New(Compression(1000))
+	for n := 0; n < 100000; n++ {
+		_ = t.Add(rand.Float64())
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, q := range benchmarkQuantiles {
+			t.Quantile(q)
+		}
+	}
+}
+
+func BenchmarkQuantiles(b *testing.B) {
+	t, _ := New(Compression(1000))
+	for n := 0; n < 100000; n++ {
+		_ = t.Add(rand.Float64())
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		t.Quantiles(benchmarkQuantiles)
+	}
+}
+
+func BenchmarkSample(b *testing.B) {
+	t, _ := New(Compression(1000))
+	for n := 0; n < 100000; n++ {
+		_ = t.Add(rand.Float64())
+	}
+	rng := newLocalRNG(42)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = t.Sample(10000, rng)
+	}
+}
+
+func benchmarkMinMax(b *testing.B, centroids int) {
+	t, _ := NewFromCentroids(100, make([]Centroid, 0))
+	for i := 0; i < centroids; i++ {
+		_ = t.summary.Add(float64(i), 1)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		t.Min()
+		t.Max()
+	}
+}
+
+func BenchmarkMinMax100(b *testing.B)  { benchmarkMinMax(b, 100) }
+func BenchmarkMinMax100k(b *testing.B) { benchmarkMinMax(b, 100000) }
+
+func BenchmarkCDFLoop(b *testing.B) {
+	t, _ := New(Compression(1000))
+	for n := 0; n < 100000; n++ {
+		_ = t.Add(rand.Float64())
+	}
+
+	values := make([]float64, 50)
+	for i := range values {
+		values[i] = float64(i) / 50
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, value := range values {
+			t.CDF(value)
+		}
+	}
+}
+
+func BenchmarkCDFs(b *testing.B) {
+	t, _ := New(Compression(1000))
+	for n := 0; n < 100000; n++ {
+		_ = t.Add(rand.Float64())
+	}
+
+	values := make([]float64, 50)
+	for i := range values {
+		values[i] = float64(i) / 50
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		t.CDFs(values)
+	}
+}
+
+func benchmarkMergeDigests() []*TDigest {
+	others := make([]*TDigest, 10)
+	for i := range others {
+		others[i], _ = New(Compression(100))
+		for n := 0; n < 10000; n++ {
+			_ = others[i].Add(rand.Float64())
+		}
+	}
+	return others
+}
+
+func BenchmarkMergeLoop(b *testing.B) {
+	others := benchmarkMergeDigests()
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		dest, _ := New(Compression(100))
+		for _, other := range others {
+			_ = dest.Merge(other)
+		}
+	}
+}
+
+func BenchmarkMergeMany(b *testing.B) {
+	others := benchmarkMergeDigests()
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		dest, _ := New(Compression(100))
+		_ = dest.MergeMany(others)
+	}
+}
+
+func benchmarkSliceData() []float64 {
+	data := make([]float64, 100000)
+	for i := range data {
+		data[i] = float64(rand.Intn(10000))
+	}
+	return data
+}
+
+func BenchmarkAddLoop(b *testing.B) {
+	data := benchmarkSliceData()
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		t, _ := New()
+		for _, x := range data {
+			_ = t.Add(x)
+		}
+	}
+}
+
+func BenchmarkNewFromSlice(b *testing.B) {
+	data := benchmarkSliceData()
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = NewFromSlice(data)
+	}
+}
+
+func benchmarkMapData() map[float64]uint64 {
+	counts := make(map[float64]uint64)
+	for _, x := range benchmarkSliceData() {
+		counts[x]++
+	}
+	return counts
+}
+
+func BenchmarkAddWeightedMapLoop(b *testing.B) {
+	counts := benchmarkMapData()
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		t, _ := New()
+		for value, count := range counts {
+			_ = t.AddWeighted(value, count)
+		}
+	}
+}
+
+func BenchmarkNewFromMap(b *testing.B) {
+	counts := benchmarkMapData()
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = NewFromMap(counts)
+	}
+}
@@ -0,0 +1,49 @@
+// Package tdigestpb converts t-digests to and from protocol buffer
+// messages, so they can be embedded in other messages: just import
+// tdigest.proto from your own .proto files.
+//
+// It lives in its own package so that users of tdigest that don't
+// need it aren't forced to depend on the protobuf libraries.
+package tdigestpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative tdigest.proto
+
+import (
+	"github.com/caio/go-tdigest/v4"
+)
+
+// ToProto exports the compression and the centroids of the digest.
+//
+// Unlike the binary serialization of the tdigest package, means are
+// kept with full precision.
+func ToProto(t *tdigest.TDigest) *TDigest {
+	centroids := make([]*Centroid, 0, t.Len())
+	t.ForEachCentroid(func(mean float64, count uint64) bool {
+		centroids = append(centroids, &Centroid{Mean: mean, Count: count})
+		return true
+	})
+
+	return &TDigest{
+		Compression: t.Compression(),
+		Centroids:   centroids,
+	}
+}
+
+// FromProto creates a new digest from a message built by ToProto.
+// The centroids are restored as they are and the options configure
+// the digest like in tdigest.New, except for the compression, which
+// comes from the message.
+//
+// This will emit an error if the compression is lower than 1, if any
+// centroid has a non-finite mean or a zero count, or if the options
+// are invalid.
+func FromProto(pb *TDigest, options ...tdigest.Option) (*tdigest.TDigest, error) {
+	centroids := make([]tdigest.Centroid, len(pb.GetCentroids()))
+	for i, c := range pb.GetCentroids() {
+		centroids[i] = tdigest.Centroid{Mean: c.GetMean(), Count: c.GetCount()}
+	}
+
+	// Options may not override the compression
+	options = append(options[:len(options):len(options)], tdigest.Compression(pb.GetCompression()))
+	return tdigest.NewFromCentroids(pb.GetCompression(), centroids, options...)
+}
@@ -0,0 +1,67 @@
+package tdigestpb
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/caio/go-tdigest/v4"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xCA10))
+	digest, _ := tdigest.New(tdigest.Compression(42))
+	for i := 0; i < 10000; i++ {
+		_ = digest.Add(rng.ExpFloat64())
+	}
+
+	data, err := proto.Marshal(ToProto(digest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var message TDigest
+	err = proto.Unmarshal(data, &message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := FromProto(&message, tdigest.Compression(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Compression() != 42 {
+		t.Errorf("Expected the compression to come from the message, got %f", restored.Compression())
+	}
+
+	if restored.Count() != digest.Count() || restored.Checksum() != digest.Checksum() {
+		t.Errorf("Expected the centroids to be restored exactly")
+	}
+
+	for _, q := range []float64{0, 0.01, 0.5, 0.99, 1} {
+		if restored.Quantile(q) != digest.Quantile(q) {
+			t.Errorf("Quantile(%.2f) changed after the round-trip: %f != %f", q, digest.Quantile(q), restored.Quantile(q))
+		}
+	}
+}
+
+func TestFromProtoErrors(t *testing.T) {
+	invalid := []*TDigest{
+		{},
+		{Compression: 100, Centroids: []*Centroid{{Mean: 1, Count: 0}}},
+		{Compression: 100, Centroids: []*Centroid{{Mean: math.NaN(), Count: 1}}},
+	}
+
+	for _, message := range invalid {
+		if _, err := FromProto(message); err == nil {
+			t.Errorf("Expected error for %v", message)
+		}
+	}
+
+	empty, err := FromProto(ToProto(tdigest.MustNew()))
+	if err != nil || empty.Count() != 0 {
+		t.Errorf("Expected empty digests to round-trip, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package tdigest
+
+import "errors"
+
+// ErrNoSnapshot is returned by Rollback when Snapshot wasn't called.
+var ErrNoSnapshot = errors.New("no snapshot to roll back to")
+
+// Snapshot saves the current state of the digest, so that it can be
+// restored later via Rollback, and returns it serialized (as in
+// ToBytes).
+//
+// Only the latest snapshot is kept. Snapshots are not cloned and are
+// discarded by Reset.
+func (t *TDigest) Snapshot() []byte {
+	b := t.ToBytes(nil)
+	// Keep our own copy: the returned slice belongs to the caller
+	t.snapshot = append([]byte(nil), b...)
+	return b
+}
+
+// Rollback restores the digest to the state saved by the latest call
+// to Snapshot, discarding every sample added since then. The snapshot
+// is kept, so rolling back again goes back to the same state.
+//
+// The state goes through serialization, so centroid means may change
+// slightly (they're stored as float32 deltas). Returns ErrNoSnapshot if
+// Snapshot wasn't called and ErrFrozen if the digest is frozen.
+func (t *TDigest) Rollback() error {
+	if t.snapshot == nil {
+		return ErrNoSnapshot
+	}
+	return t.FromBytes(t.snapshot)
+}
+
+// TDigestTransaction stages samples to be added to a digest all at
+// once, see BeginTransaction.
+type TDigestTransaction struct {
+	digest  *TDigest
+	pending *summary
+}
+
+// BeginTransaction starts staging samples for the digest: the ones
+// added to the returned transaction are only registered in the digest
+// on Commit, or dropped on Rollback. Useful when processing a batch
+// that may fail partway through.
+//
+// The digest must not be modified directly while a transaction is in
+// progress, since Commit would add the staged samples on top of those
+// changes. A transaction is not safe for concurrent use.
+func (t *TDigest) BeginTransaction() *TDigestTransaction {
+	return &TDigestTransaction{digest: t, pending: newSummary(0)}
+}
+
+// AddWeighted stages a new sample. It's validated right away, like in
+// TDigest.AddWeighted, so Commit only fails for frozen digests.
+func (tx *TDigestTransaction) AddWeighted(value float64, count uint64) error {
+	if err := validSample(value, count); err != nil {
+		return err
+	}
+	tx.pending.means = append(tx.pending.means, value)
+	tx.pending.counts = append(tx.pending.counts, count)
+	return nil
+}
+
+// Add is an alias for AddWeighted(x,1)
+func (tx *TDigestTransaction) Add(value float64) error {
+	return tx.AddWeighted(value, 1)
+}
+
+// Commit adds the staged samples to the digest, in the order they were
+// staged, and starts over with an empty stage. Returns ErrFrozen,
+// without modifying the digest, if it's frozen.
+func (tx *TDigestTransaction) Commit() error {
+	if tx.digest.frozen {
+		return ErrFrozen
+	}
+
+	var err error
+	tx.pending.ForEach(func(value float64, count uint64) bool {
+		err = tx.digest.AddWeighted(value, count)
+		return err == nil
+	})
+	tx.pending.means = tx.pending.means[:0]
+	tx.pending.counts = tx.pending.counts[:0]
+	return err
+}
+
+// Rollback drops the staged samples, leaving the digest untouched.
+func (tx *TDigestTransaction) Rollback() {
+	tx.pending.means = tx.pending.means[:0]
+	tx.pending.counts = tx.pending.counts[:0]
+}
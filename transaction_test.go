@@ -0,0 +1,115 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSnapshotRollback(t *testing.T) {
+	tdigest := uncheckedNew()
+	if err := tdigest.Rollback(); err != ErrNoSnapshot {
+		t.Errorf("Expected ErrNoSnapshot, got %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(0xCA10))
+	for i := 0; i < 1000; i++ {
+		_ = tdigest.Add(rng.Float64())
+	}
+	median := tdigest.Quantile(0.5)
+
+	snapshot := tdigest.Snapshot()
+	expected, _ := tdigest.AsBytes()
+	if string(snapshot) != string(expected) {
+		t.Errorf("Expected the snapshot to be serialized like AsBytes")
+	}
+
+	for i := 0; i < 500; i++ {
+		_ = tdigest.Add(10 + rng.Float64())
+	}
+	if tdigest.Count() != 1500 {
+		t.Fatalf("Expected 1500 samples, got %d", tdigest.Count())
+	}
+
+	// Twice: the snapshot is kept
+	for i := 0; i < 2; i++ {
+		if err := tdigest.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+		if tdigest.Count() != 1000 {
+			t.Errorf("Expected the count to go back to 1000, got %d", tdigest.Count())
+		}
+		if math.Abs(tdigest.Quantile(0.5)-median) > 1e-4 {
+			t.Errorf("Expected the median to go back to %f, got %f", median, tdigest.Quantile(0.5))
+		}
+		_ = tdigest.Add(42)
+	}
+
+	// Modifying the returned slice doesn't affect the snapshot
+	for i := range snapshot {
+		snapshot[i] = 0
+	}
+	if err := tdigest.Rollback(); err != nil || math.Abs(tdigest.Quantile(0.5)-median) > 1e-4 {
+		t.Errorf("Expected the snapshot to be unaffected by changes to the returned slice")
+	}
+
+	tdigest.Freeze()
+	if err := tdigest.Rollback(); err != ErrFrozen {
+		t.Errorf("Expected ErrFrozen, got %v", err)
+	}
+
+	tdigest.Reset()
+	if err := tdigest.Rollback(); err != ErrNoSnapshot {
+		t.Errorf("Expected Reset to discard the snapshot, got %v", err)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	tdigest := uncheckedNew()
+	rng := rand.New(rand.NewSource(0xCA10))
+	for i := 0; i < 1000; i++ {
+		_ = tdigest.Add(rng.Float64())
+	}
+	before := tdigest.Checksum()
+
+	tx := tdigest.BeginTransaction()
+	for i := 0; i < 500; i++ {
+		if err := tx.Add(rng.Float64()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tdigest.Checksum() != before {
+		t.Errorf("Expected staged samples to leave the digest untouched")
+	}
+
+	if err := tx.AddWeighted(math.NaN(), 1); err == nil {
+		t.Errorf("Expected error for an invalid sample")
+	}
+
+	tx.Rollback()
+	if err := tx.Commit(); err != nil || tdigest.Checksum() != before {
+		t.Errorf("Expected an empty commit after rolling back")
+	}
+
+	// Committing is the same as adding directly
+	direct := tdigest.Clone()
+	for i := 0; i < 500; i++ {
+		x := rng.Float64()
+		_ = tx.Add(x)
+		_ = direct.Add(x)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if tdigest.Count() != 1500 || tdigest.Checksum() != direct.Checksum() {
+		t.Errorf("Expected the commit to add the 500 staged samples, got count %d", tdigest.Count())
+	}
+
+	// Frozen digests are left untouched
+	_ = tx.AddWeighted(1, 1)
+	tdigest.Freeze()
+	frozen := tdigest.Checksum()
+	if err := tx.Commit(); err != ErrFrozen || tdigest.Checksum() != frozen {
+		t.Errorf("Expected ErrFrozen and no changes, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package tdigest
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// SlidingWindowDigest estimates quantiles over the samples registered
+// in a recent time window (e.g.: the last 5 minutes) instead of over
+// all the samples ever seen.
+//
+// The window is split in fixed-duration buckets, each an independent
+// digest: samples are added to the bucket of the current time and
+// queries merge all the buckets that are still within the window.
+// Expired buckets are recycled lazily, as time moves forward, so no
+// background goroutine is needed. The window thus slides in steps of
+// one bucket duration: smaller buckets mean a smoother window at the
+// cost of more memory and slower queries.
+//
+// Like TDigest, a SlidingWindowDigest is not safe for concurrent use.
+type SlidingWindowDigest struct {
+	buckets        []*TDigest
+	epochs         []int64
+	bucketDuration time.Duration
+	options        []tdigestOption
+	now            func() time.Time
+}
+
+// NewSlidingWindow creates a digest over a sliding window of the given
+// duration, split in buckets of bucketDuration. The window is rounded
+// up to a multiple of bucketDuration. The options configure the digest
+// of every bucket like in New.
+//
+// This will emit an error if the durations are not positive, if the
+// window is shorter than a bucket or if the options are invalid.
+func NewSlidingWindow(window, bucketDuration time.Duration, options ...tdigestOption) (*SlidingWindowDigest, error) {
+	if window <= 0 || bucketDuration <= 0 {
+		return nil, fmt.Errorf("window and bucket durations must be > 0, got %v and %v", window, bucketDuration)
+	}
+	if window < bucketDuration {
+		return nil, fmt.Errorf("window (%v) must not be shorter than a bucket (%v)", window, bucketDuration)
+	}
+
+	n := int((window + bucketDuration - 1) / bucketDuration)
+	s := &SlidingWindowDigest{
+		buckets:        make([]*TDigest, n),
+		epochs:         make([]int64, n),
+		bucketDuration: bucketDuration,
+		options:        options,
+		now:            time.Now,
+	}
+
+	for i := range s.buckets {
+		digest, err := New(options...)
+		if err != nil {
+			return nil, err
+		}
+		s.buckets[i] = digest
+		s.epochs[i] = math.MinInt64
+	}
+	return s, nil
+}
+
+// AddWeighted registers a new sample in the current bucket. Refer to
+// TDigest.AddWeighted for more details.
+func (s *SlidingWindowDigest) AddWeighted(value float64, count uint64) error {
+	epoch := s.epoch()
+	idx := s.index(epoch)
+
+	if s.epochs[idx] != epoch {
+		s.buckets[idx].Reset()
+		s.epochs[idx] = epoch
+	}
+	return s.buckets[idx].AddWeighted(value, count)
+}
+
+// Add is an alias for AddWeighted(x,1)
+func (s *SlidingWindowDigest) Add(value float64) error {
+	return s.AddWeighted(value, 1)
+}
+
+// Snapshot returns a new digest holding the samples of all the buckets
+// within the window.
+//
+// Queries on a SlidingWindowDigest merge all the buckets every time,
+// so when computing several estimations at once it's cheaper to take
+// a snapshot and query it instead.
+func (s *SlidingWindowDigest) Snapshot() (*TDigest, error) {
+	result, err := New(s.options...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = result.MergeMany(s.active())
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Quantile returns the desired percentile estimation over the samples
+// within the window. Refer to TDigest.Quantile for more details.
+func (s *SlidingWindowDigest) Quantile(q float64) float64 {
+	snapshot, err := s.Snapshot()
+	if err != nil {
+		return math.NaN()
+	}
+	return snapshot.Quantile(q)
+}
+
+// CDF computes the fraction in which all samples within the window are
+// less than or equal to the given value. Refer to TDigest.CDF for more
+// details.
+func (s *SlidingWindowDigest) CDF(value float64) float64 {
+	snapshot, err := s.Snapshot()
+	if err != nil {
+		return math.NaN()
+	}
+	return snapshot.CDF(value)
+}
+
+// Count returns the number of samples within the window.
+func (s *SlidingWindowDigest) Count() uint64 {
+	var count uint64
+	for _, bucket := range s.active() {
+		count += bucket.Count()
+	}
+	return count
+}
+
+// Returns the buckets that are still within the window
+func (s *SlidingWindowDigest) active() []*TDigest {
+	current := s.epoch()
+	oldest := current - int64(len(s.buckets)) + 1
+
+	active := make([]*TDigest, 0, len(s.buckets))
+	for i, epoch := range s.epochs {
+		if epoch >= oldest && epoch <= current {
+			active = append(active, s.buckets[i])
+		}
+	}
+	return active
+}
+
+func (s *SlidingWindowDigest) epoch() int64 {
+	return s.now().UnixNano() / int64(s.bucketDuration)
+}
+
+func (s *SlidingWindowDigest) index(epoch int64) int {
+	idx := int(epoch % int64(len(s.buckets)))
+	if idx < 0 {
+		idx += len(s.buckets)
+	}
+	return idx
+}
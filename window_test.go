@@ -0,0 +1,116 @@
+package tdigest
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestWindow(t *testing.T, clock *fakeClock) *SlidingWindowDigest {
+	window, err := NewSlidingWindow(5*time.Minute, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.now = clock.Now
+	return window
+}
+
+func TestSlidingWindowDigest(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	window := newTestWindow(t, clock)
+
+	if window.Count() != 0 || !math.IsNaN(window.Quantile(0.5)) {
+		t.Errorf("Expected an empty window")
+	}
+
+	// One minute of small values, then four of large ones
+	for i := 0; i < 1000; i++ {
+		_ = window.Add(float64(i % 10))
+	}
+	for m := 0; m < 4; m++ {
+		clock.Advance(time.Minute)
+		for i := 0; i < 1000; i++ {
+			_ = window.Add(float64(100 + i%10))
+		}
+	}
+
+	if window.Count() != 5000 {
+		t.Errorf("Expected 5000 samples in the window, got %d", window.Count())
+	}
+
+	if q := window.Quantile(0.1); q >= 10 {
+		t.Errorf("Expected the small values to be within the window, got p10=%f", q)
+	}
+
+	// The first minute slides out of the window
+	clock.Advance(time.Minute)
+	if window.Count() != 4000 {
+		t.Errorf("Expected 4000 samples in the window, got %d", window.Count())
+	}
+
+	if q := window.Quantile(0.1); q < 100 {
+		t.Errorf("Expected the small values to have expired, got p10=%f", q)
+	}
+
+	if cdf := window.CDF(50); cdf != 0 {
+		t.Errorf("Expected CDF(50) to be 0, got %f", cdf)
+	}
+
+	// Adding to a recycled bucket only sees new data
+	_ = window.Add(1)
+	if window.Count() != 4001 {
+		t.Errorf("Expected 4001 samples in the window, got %d", window.Count())
+	}
+
+	snapshot, err := window.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Count() != 4001 || snapshot.Validate() != nil {
+		t.Errorf("Unexpected snapshot: count=%d", snapshot.Count())
+	}
+
+	// Idle for longer than the window
+	clock.Advance(time.Hour)
+	if window.Count() != 0 || !math.IsNaN(window.Quantile(0.5)) {
+		t.Errorf("Expected the window to be empty after an hour")
+	}
+}
+
+func TestNewSlidingWindowErrors(t *testing.T) {
+	invalid := []struct {
+		window, bucket time.Duration
+		options        []tdigestOption
+	}{
+		{0, time.Second, nil},
+		{time.Minute, 0, nil},
+		{time.Second, time.Minute, nil},
+		{time.Minute, time.Second, []tdigestOption{Compression(0)}},
+	}
+
+	for _, c := range invalid {
+		if _, err := NewSlidingWindow(c.window, c.bucket, c.options...); err == nil {
+			t.Errorf("Expected error for window=%v bucket=%v", c.window, c.bucket)
+		}
+	}
+
+	window, err := NewSlidingWindow(90*time.Second, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(window.buckets) != 2 {
+		t.Errorf("Expected the window to be rounded up to 2 buckets, got %d", len(window.buckets))
+	}
+}